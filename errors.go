@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Error codes sent in the "code" field of error responses. The catalog in README.md
+// lists them together with their status codes, keep both in sync.
+const (
+	errCodeInvalidJSON              = "invalid_json"
+	errCodeInvalidID                = "invalid_id"
+	errCodeInvalidParameter         = "invalid_parameter"
+	errCodeWeakPassword             = "weak_password"
+	errCodeInvalidResetToken        = "invalid_reset_token"
+	errCodeInvalidVerificationToken = "invalid_verification_token"
+	errCodeInvalidOIDCState         = "invalid_oidc_state"
+	errCodeChirpTooLong             = "chirp_too_long"
+	errCodeChirpNotAllowed          = "chirp_not_allowed"
+	errCodeReplyTargetNotFound      = "reply_target_not_found"
+	errCodeQuoteTargetNotFound      = "quote_target_not_found"
+	errCodeRechirpNotEditable       = "rechirp_not_editable"
+	errCodeCannotFollowSelf         = "cannot_follow_self"
+	errCodeUnauthorized             = "unauthorized"
+	errCodeInvalidCredentials       = "invalid_credentials"
+	errCodeInvalidTwoFactorCode     = "invalid_two_factor_code"
+	errCodeOIDCLoginFailed          = "oidc_login_failed"
+	errCodeRefreshTokenReused       = "refresh_token_reused"
+	errCodeEmailNotVerified         = "email_not_verified"
+	errCodeForbidden                = "forbidden"
+	errCodeInsufficientScope        = "insufficient_scope"
+	errCodeAccountSuspended         = "account_suspended"
+	errCodeAccountBanned            = "account_banned"
+	errCodeEditWindowExpired        = "edit_window_expired"
+	errCodeRestorePeriodExpired     = "restore_period_expired"
+	errCodeChirpNotFound            = "chirp_not_found"
+	errCodeUserNotFound             = "user_not_found"
+	errCodeWordNotFound             = "word_not_found"
+	errCodeSessionNotFound          = "session_not_found"
+	errCodeProviderNotFound         = "provider_not_found"
+	errCodeIdentityNotFound         = "identity_not_found"
+	errCodeAPITokenNotFound         = "api_token_not_found"
+	errCodeReportNotFound           = "report_not_found"
+	errCodeEmailTaken               = "email_taken"
+	errCodeEmailAlreadyVerified     = "email_already_verified"
+	errCodeTwoFactorEnabled         = "two_factor_already_enabled"
+	errCodeTwoFactorNotEnrolled     = "two_factor_not_enrolled"
+	errCodeIdentityNotLinked        = "identity_not_linked"
+	errCodeIdentityAlreadyLinked    = "identity_already_linked"
+	errCodeLastIdentity             = "last_identity"
+	errCodeAlreadyRechirped         = "already_rechirped"
+	errCodeAlreadyReported          = "already_reported"
+	errCodeReportAlreadyResolved    = "report_already_resolved"
+	errCodeTooManyLoginAttempts     = "too_many_login_attempts"
+	errCodeTwoFactorUnavailable     = "two_factor_unavailable"
+	errCodeInternal                 = "internal_error"
+	errCodeProviderUnavailable      = "provider_unavailable"
+)
+
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+type errorEnvelope struct {
+	Error APIError `json:"error"`
+}
+
+type requestIDKey struct{}
+
+// middlewareRequestID gives every request an ID, reusing a sane X-Request-ID header from the client,
+// so error responses and logs can be matched.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		resp.Header().Set("X-Request-ID", requestID)
+		next.ServeHTTP(resp, req.WithContext(context.WithValue(req.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(req *http.Request) string {
+	requestID, _ := req.Context().Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func responseError(resp http.ResponseWriter, req *http.Request, status int, code, message string) {
+	responseErrorDetails(resp, req, status, code, message, nil)
+}
+
+// responseErrorDetails is responseError with extra data for the client, like the limits that were exceeded.
+func responseErrorDetails(resp http.ResponseWriter, req *http.Request, status int, code, message string, details interface{}) {
+	respBody := errorEnvelope{
+		Error: APIError{
+			Code:      code,
+			Message:   message,
+			RequestID: requestID(req),
+			Details:   details,
+		},
+	}
+	responseJSON(resp, status, respBody)
+}
+
+// responseInternalError hides the cause from the client, it is expected to be logged by the caller.
+func responseInternalError(resp http.ResponseWriter, req *http.Request) {
+	log.Printf("Request %s failed", requestID(req))
+	responseError(resp, req, 500, errCodeInternal, "Something went wrong")
+}
+
+func responseUnauthorized(resp http.ResponseWriter, req *http.Request) {
+	responseError(resp, req, 401, errCodeUnauthorized, "A valid access token is required")
+}
+
+func responseInvalidJSON(resp http.ResponseWriter, req *http.Request) {
+	responseError(resp, req, 400, errCodeInvalidJSON, "Request body is not valid JSON")
+}
+
+func responseInvalidID(resp http.ResponseWriter, req *http.Request, name string) {
+	responseError(resp, req, 400, errCodeInvalidID, name+" is not a valid UUID")
+}
+
+// isUniqueViolation tells whether a query failed because a unique constraint was violated.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlerGetChirpErrors(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	tests := []struct {
+		name     string
+		chirpID  string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Invalid ID",
+			chirpID:  "chirp",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+		{
+			name:     "Unknown chirp",
+			chirpID:  uuid.New().String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/chirps/"+test.chirpID, nil)
+			req.SetPathValue("chirpID", test.chirpID)
+			rec := httptest.NewRecorder()
+			cfg.handlerGetChirp(rec, req)
+			if rec.Code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerGetChirp() status = %d, code = %q, want %d %q", rec.Code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+	handler := middlewareRequestID(cfg.middlewareAuth(cfg.handlerGetAPITokens))
+
+	tests := []struct {
+		name     string
+		clientID string
+		wantSame bool
+	}{
+		{
+			name:     "Client ID",
+			clientID: "client-request-1",
+			wantSame: true,
+		},
+		{
+			name: "No client ID",
+		},
+		{
+			name:     "Client ID with spaces",
+			clientID: "client request",
+		},
+		{
+			name:     "Client ID too long",
+			clientID: strings.Repeat("a", 129),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/tokens", nil)
+			if test.clientID != "" {
+				req.Header.Set("X-Request-ID", test.clientID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get("X-Request-ID")
+			if (requestID == test.clientID) != test.wantSame || requestID == "" {
+				t.Errorf("X-Request-ID = %q, client sent %q", requestID, test.clientID)
+			}
+			body := errorEnvelope{}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if rec.Code != 401 || body.Error.Code != errCodeUnauthorized || body.Error.RequestID != requestID {
+				t.Errorf("Response status = %d, error = %+v, want 401 %q with request ID %q", rec.Code, body.Error, errCodeUnauthorized, requestID)
+			}
+		})
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/contentfilter"
 	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/pagination"
 	"github.com/google/uuid"
+	"github.com/rivo/uniseg"
 )
 
 import _ "github.com/lib/pq"
@@ -39,12 +44,13 @@ func (cfg *apiConfig) handlerNRequests(resp http.ResponseWriter, req *http.Reque
 func (cfg *apiConfig) handlerResetRequests(resp http.ResponseWriter, req *http.Request) {
 	cfg.fileserverHits.Store(0)
 	if cfg.platformAPI != "dev" {
-		resp.WriteHeader(403)
+		responseError(resp, req, 403, errCodeForbidden, "Resetting is only available on the dev platform")
 		return
 	}
 	err := cfg.dbQueries.ResetUsers(req.Context())
 	if err != nil {
 		log.Printf("Error resetting users: %s", err)
+		responseInternalError(resp, req)
 	}
 }
 
@@ -55,7 +61,6 @@ func handlerFunc(resp http.ResponseWriter, req *http.Request) {
 }
 
 func responseJSON(resp http.ResponseWriter, code int, response interface{}) {
-	resp.WriteHeader(code)
 	dat, err := json.Marshal(response)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
@@ -63,222 +68,532 @@ func responseJSON(resp http.ResponseWriter, code int, response interface{}) {
 		return
 	}
 	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
 	resp.Write(dat)
 	return
 }
 
-func CleanedBody(msg string) string {
-	splitted := strings.Split(msg, " ")
-	for i, word := range splitted {
-		word = strings.ToLower(word)
-		if word == "kerfuffle" || word == "sharbert" || word == "fornax" {
-			splitted[i] = "****"
-		}
-	}
-	return strings.Join(splitted, " ")
+type Chirp struct {
+	ID              uuid.UUID  `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Body            string     `json:"body"`
+	UserID          uuid.UUID  `json:"user_id"`
+	LikeCount       int64      `json:"like_count"`
+	LikedByMe       bool       `json:"liked_by_me"`
+	InReplyTo       *uuid.UUID `json:"in_reply_to"`
+	ReplyCount      int64      `json:"reply_count"`
+	Kind            string     `json:"kind"`
+	ReferenceID     *uuid.UUID `json:"reference_id"`
+	Edited          bool       `json:"edited"`
+	Hidden          bool       `json:"hidden,omitempty"`
+	ReferencedChirp *Chirp     `json:"referenced_chirp,omitempty"`
 }
 
-type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+func chirpFromDB(chirp database.Chirp, likeCount int64, likedByMe bool, replyCount int64) Chirp {
+	respBody := Chirp{
+		ID:         chirp.ID,
+		CreatedAt:  chirp.CreatedAt,
+		UpdatedAt:  chirp.UpdatedAt,
+		Body:       chirp.Body,
+		UserID:     chirp.UserID,
+		LikeCount:  likeCount,
+		LikedByMe:  likedByMe,
+		ReplyCount: replyCount,
+		Kind:       chirp.Kind,
+		Edited:     chirp.EditedAt.Valid,
+		Hidden:     chirp.HiddenAt.Valid,
+	}
+	if chirp.InReplyTo.Valid {
+		respBody.InReplyTo = &chirp.InReplyTo.UUID
+	}
+	if chirp.ReferenceID.Valid {
+		respBody.ReferenceID = &chirp.ReferenceID.UUID
+	}
+	return respBody
 }
 
-func (cfg *apiConfig) handlerChirps(resp http.ResponseWriter, req *http.Request) {
-	token, err := auth.GetBearerToken(req.Header)
-	if err != nil {
-		log.Printf("Error getting JWT: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
+// attachReferences loads the rechirped and quoted chirps of a page with a single query.
+// Hidden chirps are only attached for moderators.
+func (cfg *apiConfig) attachReferences(ctx context.Context, includeHidden bool, chirps []*Chirp) error {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.ReferenceID != nil {
+			ids = append(ids, *chirp.ReferenceID)
 		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
-		return
+	}
+	if len(ids) == 0 {
+		return nil
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.keyJWT)
+	referenced, err := cfg.dbQueries.GetChirpsByIDs(ctx, database.GetChirpsByIDsParams{
+		Ids:           ids,
+		IncludeHidden: includeHidden,
+	})
 	if err != nil {
-		log.Printf("Error validating JWT: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
+		return err
+	}
+
+	byID := make(map[uuid.UUID]Chirp, len(referenced))
+	for _, ch := range referenced {
+		byID[ch.ID] = chirpFromDB(ch, 0, false, 0)
+	}
+	for _, chirp := range chirps {
+		if chirp.ReferenceID == nil {
+			continue
 		}
-		respBody := returnVals{
-			Error: "Something went wrong",
+		if ref, ok := byID[*chirp.ReferenceID]; ok {
+			chirp.ReferencedChirp = &ref
 		}
-		responseJSON(resp, 401, respBody)
-		return
 	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerChirps(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
 
 	type parameters struct {
-		Body    string    `json:"body"`
-		User_id uuid.UUID `json:"user_id"` //we don't need it, since user's ID is found through JWT
+		Body      string     `json:"body"`
+		User_id   uuid.UUID  `json:"user_id"` //we don't need it, since user's ID is found through JWT
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	if !cfg.checkCanPost(resp, req, userID) {
+		return
+	}
+
+	filtered, ok := cfg.checkChirpBody(resp, req, userID, params.Body)
+	if !ok {
+		return
+	}
+
+	var inReplyTo uuid.NullUUID
+	if params.InReplyTo != nil {
+		_, err = cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: *params.InReplyTo})
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(resp, req, 400, errCodeReplyTargetNotFound, "Chirp to reply to doesn't exist")
+			return
 		}
-		respBody := returnVals{
-			Error: "Something went wrong",
+		if err != nil {
+			log.Printf("Error getting chirp: %s", err)
+			responseInternalError(resp, req)
+			return
 		}
-		responseJSON(resp, 500, respBody)
-		return
+		inReplyTo = uuid.NullUUID{UUID: *params.InReplyTo, Valid: true}
 	}
 
-	if len(params.Body) > 140 {
-		type returnVals struct {
-			Error string `json:"error"`
+	kind := "chirp"
+	var referenceID uuid.NullUUID
+	if params.QuoteOf != nil {
+		quoted, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: *params.QuoteOf})
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(resp, req, 400, errCodeQuoteTargetNotFound, "Chirp to quote doesn't exist")
+			return
 		}
-		respBody := returnVals{
-			Error: "Chirp is too long",
+		if err != nil {
+			log.Printf("Error getting chirp: %s", err)
+			responseInternalError(resp, req)
+			return
 		}
-		responseJSON(resp, 400, respBody)
-		return
+		kind = "quote"
+		referenceID = uuid.NullUUID{UUID: originalChirpID(quoted.Chirp), Valid: true}
 	}
 
-	cleaned := CleanedBody(params.Body)
-	chirp, err := cfg.dbQueries.CreateChirp(req.Context(), database.CreateChirpParams{cleaned, userID})
+	chirp, err := cfg.dbQueries.CreateChirp(req.Context(), database.CreateChirpParams{
+		Body:        filtered.Text,
+		UserID:      userID,
+		InReplyTo:   inReplyTo,
+		Kind:        kind,
+		ReferenceID: referenceID,
+	})
 	if err != nil {
 		log.Printf("Error creating user: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInternalError(resp, req)
 		return
 	}
-	respBody := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+
+	cfg.flagChirp(req.Context(), chirp.ID, filtered.Flagged)
+
+	respBody := chirpFromDB(chirp, 0, false, 0)
+	err = cfg.attachReferences(req.Context(), false, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Error getting referenced chirp: %s", err)
 	}
 	responseJSON(resp, 201, respBody)
 }
 
-func (cfg *apiConfig) handlerGetChirps(resp http.ResponseWriter, req *http.Request) {
-	authorID := req.URL.Query().Get("author_id")
+// checkChirpBody applies the length limit of the author's tier and the content filter,
+// writing a 400 response when the body is not allowed.
+func (cfg *apiConfig) checkChirpBody(resp http.ResponseWriter, req *http.Request, userID uuid.UUID, body string) (contentfilter.Result, bool) {
+	author, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return contentfilter.Result{}, false
+	}
 
-	chirps := []database.Chirp{}
-	var err error
+	maxLength, tier := cfg.maxChirpLength, "free"
+	if author.IsChirpyRed {
+		maxLength, tier = cfg.maxChirpLengthRed, "chirpy_red"
+	}
+	length := uniseg.GraphemeClusterCount(body)
+	if length > maxLength {
+		type details struct {
+			Length    int    `json:"length"`
+			MaxLength int    `json:"max_length"`
+			Tier      string `json:"tier"`
+		}
+		message := fmt.Sprintf("Chirp is too long: %d characters, the limit is %d", length, maxLength)
+		responseErrorDetails(resp, req, 400, errCodeChirpTooLong, message, details{
+			Length:    length,
+			MaxLength: maxLength,
+			Tier:      tier,
+		})
+		return contentfilter.Result{}, false
+	}
 
-	if authorID == "" {
-		chirps, err = cfg.dbQueries.GetChirps(req.Context())
-		if err != nil {
-			log.Printf("Error getting chirps: %s", err)
-			type returnVals struct {
-				Error string `json:"error"`
-			}
-			respBody := returnVals{
-				Error: "Something went wrong",
-			}
-			responseJSON(resp, 500, respBody)
-			return
+	filtered := cfg.contentFilter.Apply(body)
+	if len(filtered.Rejected) > 0 {
+		type details struct {
+			Words []string `json:"words"`
 		}
-	} else {
-		authorUUID, err := uuid.Parse(authorID)
+		message := "Chirp contains words that are not allowed: " + strings.Join(filtered.Rejected, ", ")
+		responseErrorDetails(resp, req, 400, errCodeChirpNotAllowed, message, details{Words: filtered.Rejected})
+		return contentfilter.Result{}, false
+	}
+
+	return filtered, true
+}
+
+// flagChirp saves the chirp for review when the content filter flagged some of its words.
+func (cfg *apiConfig) flagChirp(ctx context.Context, chirpID uuid.UUID, words []string) {
+	if len(words) == 0 {
+		return
+	}
+
+	err := cfg.dbQueries.CreateFlaggedChirp(ctx, database.CreateFlaggedChirpParams{
+		ChirpID: chirpID,
+		Words:   words,
+	})
+	if err != nil {
+		log.Printf("Error flagging chirp for review: %s", err)
+	}
+}
+
+// originalChirpID makes rechirping or quoting a rechirp point at the chirp that was rechirped.
+func originalChirpID(chirp database.Chirp) uuid.UUID {
+	if chirp.Kind == "rechirp" && chirp.ReferenceID.Valid {
+		return chirp.ReferenceID.UUID
+	}
+	return chirp.ID
+}
+
+type ChirpsPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+func chirpsPage(chirps []Chirp, limit int32) ChirpsPage {
+	if len(chirps) <= int(limit) {
+		return ChirpsPage{Chirps: chirps}
+	}
+
+	chirps = chirps[:limit]
+	last := chirps[len(chirps)-1]
+	return ChirpsPage{
+		Chirps:     chirps,
+		NextCursor: pagination.EncodeCursor(last.CreatedAt, last.ID),
+	}
+}
+
+func chirpPointers(chirps []Chirp) []*Chirp {
+	ptrs := make([]*Chirp, len(chirps))
+	for i := range chirps {
+		ptrs[i] = &chirps[i]
+	}
+	return ptrs
+}
+
+// parsePage reads the limit and cursor query parameters shared by the paginated endpoints.
+func parsePage(resp http.ResponseWriter, req *http.Request) (int32, sql.NullTime, uuid.NullUUID, bool) {
+	query := req.URL.Query()
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return 0, sql.NullTime{}, uuid.NullUUID{}, false
+	}
+
+	cursorCreatedAt, cursorID, err := pagination.ParseCursor(query.Get("cursor"))
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return 0, sql.NullTime{}, uuid.NullUUID{}, false
+	}
+
+	return limit, cursorCreatedAt, cursorID, true
+}
+
+func (cfg *apiConfig) handlerGetChirps(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	var authorUUID uuid.NullUUID
+	if authorID := query.Get("author_id"); authorID != "" {
+		parsed, err := uuid.Parse(authorID)
 		if err != nil {
 			log.Printf("Error parsing to UUID: %s", err)
-			type returnVals struct {
-				Error string `json:"error"`
-			}
-			respBody := returnVals{
-				Error: "Something went wrong",
-			}
-			responseJSON(resp, 500, respBody)
+			responseError(resp, req, 400, errCodeInvalidParameter, "author_id is not a valid UUID")
 			return
 		}
-		chirps, err = cfg.dbQueries.GetChirpsAuthor(req.Context(), authorUUID)
+		authorUUID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	viewerID := authViewerID(req)
+	includeHidden := authCanSeeHidden(req)
+	var err error
+
+	// one extra row tells us whether there is a next page
+	chirps := []Chirp{}
+	switch query.Get("sort") {
+	case "", "asc":
+		var rows []database.GetChirpsPageAscRow
+		rows, err = cfg.dbQueries.GetChirpsPageAsc(req.Context(), database.GetChirpsPageAscParams{
+			ViewerID:        viewerID,
+			IncludeHidden:   includeHidden,
+			AuthorID:        authorUUID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+		for _, row := range rows {
+			chirps = append(chirps, chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount))
+		}
+	case "desc":
+		var rows []database.GetChirpsPageDescRow
+		rows, err = cfg.dbQueries.GetChirpsPageDesc(req.Context(), database.GetChirpsPageDescParams{
+			ViewerID:        viewerID,
+			IncludeHidden:   includeHidden,
+			AuthorID:        authorUUID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+		for _, row := range rows {
+			chirps = append(chirps, chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount))
+		}
+	default:
+		responseError(resp, req, 400, errCodeInvalidParameter, "sort must be asc or desc")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	page := chirpsPage(chirps, limit)
+	err = cfg.attachReferences(req.Context(), includeHidden, chirpPointers(page.Chirps))
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 200, page)
+}
+
+type ChirpSearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type ChirpSearchPage struct {
+	Results    []ChirpSearchResult `json:"results"`
+	NextOffset int32               `json:"next_offset,omitempty"`
+}
+
+// snippetReplacer turns the match markers of SearchChirps into <mark> tags.
+var snippetReplacer = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// searchSnippet HTML-escapes the headline of a chirp, so that the <mark> tags are its only markup.
+func searchSnippet(headline string) string {
+	return snippetReplacer.Replace(html.EscapeString(headline))
+}
+
+func (cfg *apiConfig) handlerSearchChirps(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	searchQuery := strings.TrimSpace(query.Get("q"))
+	if searchQuery == "" {
+		responseError(resp, req, 400, errCodeInvalidParameter, "q is required")
+		return
+	}
+
+	var authorUUID uuid.NullUUID
+	if authorID := query.Get("author_id"); authorID != "" {
+		parsed, err := uuid.Parse(authorID)
 		if err != nil {
-			log.Printf("Error getting chirps: %s", err)
-			type returnVals struct {
-				Error string `json:"error"`
-			}
-			respBody := returnVals{
-				Error: "Something went wrong",
-			}
-			responseJSON(resp, 500, respBody)
+			log.Printf("Error parsing to UUID: %s", err)
+			responseError(resp, req, 400, errCodeInvalidParameter, "author_id is not a valid UUID")
 			return
 		}
+		authorUUID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	// without sort the results are ordered by rank
+	orderChirps := query.Get("sort")
+	if orderChirps != "" && orderChirps != "asc" && orderChirps != "desc" {
+		responseError(resp, req, 400, errCodeInvalidParameter, "sort must be asc or desc")
+		return
+	}
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	offset, err := pagination.ParseOffset(query.Get("offset"))
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	viewerID := authViewerID(req)
+	includeHidden := authCanSeeHidden(req)
+
+	rows, err := cfg.dbQueries.SearchChirps(req.Context(), database.SearchChirpsParams{
+		ViewerID:      viewerID,
+		SearchQuery:   searchQuery,
+		IncludeHidden: includeHidden,
+		AuthorID:      authorUUID,
+		Sort:          orderChirps,
+		PageLimit:     limit + 1,
+		PageOffset:    offset,
+	})
+	if err != nil {
+		log.Printf("Error searching chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	var nextOffset int32
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		nextOffset = offset + limit
 	}
 
-	chirpsResponse := make([]Chirp, len(chirps))
-	for i, ch := range chirps {
-		respBody := Chirp{
-			ID:        ch.ID,
-			CreatedAt: ch.CreatedAt,
-			UpdatedAt: ch.UpdatedAt,
-			Body:      ch.Body,
-			UserID:    ch.UserID,
+	results := make([]ChirpSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = ChirpSearchResult{
+			Chirp:   chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount),
+			Rank:    row.Rank,
+			Snippet: searchSnippet(row.Snippet),
 		}
-		chirpsResponse[i] = respBody
 	}
 
-	orderChirps := req.URL.Query().Get("sort")
-	if orderChirps == "desc" {
-		sort.Slice(chirpsResponse, func(i, j int) bool { return chirpsResponse[j].CreatedAt.Before(chirpsResponse[i].CreatedAt) })
+	refs := make([]*Chirp, len(results))
+	for i := range results {
+		refs[i] = &results[i].Chirp
+	}
+	err = cfg.attachReferences(req.Context(), includeHidden, refs)
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
 	}
 
-	responseJSON(resp, 200, chirpsResponse)
+	responseJSON(resp, 200, ChirpSearchPage{
+		Results:    results,
+		NextOffset: nextOffset,
+	})
 }
 
 func (cfg *apiConfig) handlerGetChirp(resp http.ResponseWriter, req *http.Request) {
 	path := req.PathValue("chirpID")
 	chirpUUID, err := uuid.Parse(path)
 	if err != nil {
-		log.Printf("Error parsing to UUID: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetChirp(req.Context(), chirpUUID)
+	viewerID := authViewerID(req)
+	includeHidden := authCanSeeHidden(req)
+
+	chirp, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{
+		ViewerID:      viewerID,
+		ID:            chirpUUID,
+		IncludeHidden: includeHidden,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting chirp: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 404, respBody)
+		responseInternalError(resp, req)
 		return
 	}
 
-	respBody := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	respBody := chirpFromDB(chirp.Chirp, chirp.LikeCount, chirp.LikedByMe, chirp.ReplyCount)
+	err = cfg.attachReferences(req.Context(), includeHidden, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Error getting referenced chirp: %s", err)
+		responseInternalError(resp, req)
+		return
 	}
 	responseJSON(resp, 200, respBody)
 }
 
+const accessTokenExpiration = time.Hour
+
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Email          string     `json:"email"`
+	Token          string     `json:"token"`
+	RefreshToken   string     `json:"refresh_token"`
+	IsChirpyRed    bool       `json:"is_chirpy_red"`
+	EmailVerified  bool       `json:"email_verified"`
+	PendingEmail   string     `json:"pending_email,omitempty"`
+	Role           string     `json:"role,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	Banned         bool       `json:"banned,omitempty"`
+}
+
+func userFromDB(user database.User) User {
+	respBody := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email.String,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+		Role:          user.Role,
+		Banned:        user.Banned,
+	}
+	if user.SuspendedUntil.Valid {
+		respBody.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	return respBody
 }
 
 func (cfg *apiConfig) handlerNewUser(resp http.ResponseWriter, req *http.Request) {
@@ -292,61 +607,44 @@ func (cfg *apiConfig) handlerNewUser(resp http.ResponseWriter, req *http.Request
 	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInvalidJSON(resp, req)
 		return
 	}
 
-	if params.Password == "" {
-		log.Printf("Password is not provided: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+	email, err := parseEmail(params.Email)
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	if !cfg.checkNewPassword(resp, req, params.Password) {
 		return
 	}
 
-	hash, err := auth.HashPassword(params.Password)
+	hash, err := cfg.passwordHasher.Hash(params.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInternalError(resp, req)
 		return
 	}
 
-	user, err := cfg.dbQueries.CreateUser(req.Context(), database.CreateUserParams{sql.NullString{String: params.Email, Valid: true}, hash})
+	user, err := cfg.dbQueries.CreateUser(req.Context(), database.CreateUserParams{sql.NullString{String: email, Valid: true}, hash})
+	if isUniqueViolation(err) {
+		responseError(resp, req, 409, errCodeEmailTaken, "A user with this email already exists")
+		return
+	}
 	if err != nil {
 		log.Printf("Error creating user: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInternalError(resp, req)
 		return
 	}
-	respBody := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email.String,
-		IsChirpyRed: user.IsChirpyRed,
+
+	err = cfg.sendEmailVerification(req.Context(), user.ID, email)
+	if err != nil {
+		log.Printf("Error sending verification email: %s", err)
 	}
-	responseJSON(resp, 201, respBody)
+
+	responseJSON(resp, 201, userFromDB(user))
 }
 
 func (cfg *apiConfig) handlerLogin(resp http.ResponseWriter, req *http.Request) {
@@ -360,171 +658,74 @@ func (cfg *apiConfig) handlerLogin(resp http.ResponseWriter, req *http.Request)
 	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInvalidJSON(resp, req)
 		return
 	}
 
-	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), sql.NullString{String: params.Email, Valid: true})
-	hashCheck, _ := auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil || hashCheck == false {
-		log.Printf("Error: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+	if !cfg.reserveLoginAttempt(resp, req, params.Email) {
 		return
 	}
 
-	refresh, err := auth.MakeRefreshToken()
-	if err != nil {
-		log.Printf("Error making Refresh Token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
-		return
-	}
-
-	now := time.Now().UTC()
-	exp, _ := time.ParseDuration("1440h")
-	expiration := now.Add(exp)
-	refreshToken, err := cfg.dbQueries.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{refresh, user.ID, expiration})
-	if err != nil {
-		log.Printf("Error creating Refresh Token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), sql.NullString{String: params.Email, Valid: true})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
-
-	signedToken, err := auth.MakeJWT(user.ID, cfg.keyJWT)
-	if err != nil {
-		log.Printf("Error making JWT: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+	hashCheck, rehash, _ := cfg.passwordHasher.Check(params.Password, user.HashedPassword)
+	if err != nil || hashCheck == false {
+		responseError(resp, req, 401, errCodeInvalidCredentials, "Incorrect email or password")
 		return
 	}
-
-	respBody := User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email.String,
-		Token:        signedToken,
-		RefreshToken: refreshToken.Token,
-		IsChirpyRed:  user.IsChirpyRed,
+	cfg.releaseLoginAttempt(req, params.Email)
+	if rehash {
+		cfg.rehashPassword(req.Context(), user.ID, params.Password)
 	}
-	responseJSON(resp, 200, respBody)
-}
-
-func (cfg *apiConfig) handlerRefresh(resp http.ResponseWriter, req *http.Request) {
-	refreshToken, err := auth.GetBearerToken(req.Header)
-	if err != nil {
-		log.Printf("Error getting Bearer token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+	if !checkNotSuspended(resp, req, user) {
 		return
 	}
 
-	expiration, err := cfg.dbQueries.GetExpirationFromRefreshToken(req.Context(), refreshToken)
-	if err != nil || expiration.RevokedAt.Valid || expiration.ExpiresAt.Before(time.Now().UTC()) {
-		log.Printf("Refresh token doesn't exist or expired")
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+	userTOTP, err := cfg.dbQueries.GetUserTOTP(req.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting TOTP: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
-
-	userID, err := cfg.dbQueries.GetUserFromRefreshToken(req.Context(), refreshToken)
-	if err != nil {
-		log.Printf("Error getting User from Refresh token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+	if err == nil && userTOTP.EnabledAt.Valid {
+		cfg.startLoginChallenge(resp, req, user.ID)
 		return
 	}
 
-	signedToken, err := auth.MakeJWT(userID, cfg.keyJWT)
-	if err != nil {
-		log.Printf("Error making JWT: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+	cfg.completeLogin(resp, req, user)
+}
+
+// completeLogin starts a session for the user who passed all the checks and returns the tokens.
+// Banned and suspended users are refused here too, since the logins with two-factor
+// authentication and identity providers end here.
+func (cfg *apiConfig) completeLogin(resp http.ResponseWriter, req *http.Request, user database.User) {
+	if !checkNotSuspended(resp, req, user) {
 		return
 	}
+	cfg.succeedLogin(req, user.Email.String)
 
-	respBody := User{
-		Token: signedToken,
-	}
-	responseJSON(resp, 200, respBody)
-}
-
-func (cfg *apiConfig) handlerRevoke(resp http.ResponseWriter, req *http.Request) {
-	refreshToken, err := auth.GetBearerToken(req.Header)
+	refreshToken, err := cfg.startSession(req, user.ID)
 	if err != nil {
-		log.Printf("Error getting Bearer token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+		log.Printf("Error creating Refresh Token: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
 
-	err = cfg.dbQueries.RevokeRefreshToken(req.Context(), refreshToken)
+	signedToken, err := auth.MakeJWT(user.ID, user.Role, cfg.jwtKeys, accessTokenExpiration)
 	if err != nil {
-		log.Printf("Error revoking Refresh token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		log.Printf("Error making JWT: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
 
-	resp.WriteHeader(204)
+	respBody := userFromDB(user)
+	respBody.Token = signedToken
+	respBody.RefreshToken = refreshToken
+	responseJSON(resp, 200, respBody)
 }
 
 func (cfg *apiConfig) handlerUpdateUser(resp http.ResponseWriter, req *http.Request) {
@@ -533,93 +734,76 @@ func (cfg *apiConfig) handlerUpdateUser(resp http.ResponseWriter, req *http.Requ
 		Password string `json:"password"`
 	}
 
-	accessToken, err := auth.GetBearerToken(req.Header)
+	userID := authUserID(req)
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error getting Bearer token: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(accessToken, cfg.keyJWT)
+	email, err := parseEmail(params.Email)
 	if err != nil {
-		log.Printf("Error validating JWT: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 401, respBody)
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
 		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	if !cfg.checkNewPassword(resp, req, params.Password) {
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
 
-	if params.Password == "" {
-		log.Printf("Password is not provided: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
+	// A new email waits in pending_email until it is verified; the same email as now cancels the pending one.
+	var pendingEmail sql.NullString
+	if email != user.Email.String {
+		other, err := cfg.dbQueries.GetUserByEmail(req.Context(), sql.NullString{String: email, Valid: true})
+		if err == nil && other.ID != userID {
+			responseError(resp, req, 409, errCodeEmailTaken, "A user with this email already exists")
+			return
 		}
-		respBody := returnVals{
-			Error: "Something went wrong",
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting user: %s", err)
+			responseInternalError(resp, req)
+			return
 		}
-		responseJSON(resp, 500, respBody)
-		return
+		pendingEmail = sql.NullString{String: email, Valid: true}
 	}
 
-	hash, err := auth.HashPassword(params.Password)
+	hash, err := cfg.passwordHasher.Hash(params.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInternalError(resp, req)
 		return
 	}
 
-	user, err := cfg.dbQueries.UpdateEmailPassword(req.Context(), database.UpdateEmailPasswordParams{userID, sql.NullString{String: params.Email, Valid: true}, hash})
+	updated, err := cfg.dbQueries.UpdatePendingEmailPassword(req.Context(), database.UpdatePendingEmailPasswordParams{
+		ID:             userID,
+		PendingEmail:   pendingEmail,
+		HashedPassword: hash,
+	})
 	if err != nil {
 		log.Printf("Error updating User: %s", err)
-		type returnVals struct {
-			Error string `json:"error"`
-		}
-		respBody := returnVals{
-			Error: "Something went wrong",
-		}
-		responseJSON(resp, 500, respBody)
+		responseInternalError(resp, req)
 		return
 	}
 
-	respBody := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email.String,
-		IsChirpyRed: user.IsChirpyRed,
+	if pendingEmail.Valid && pendingEmail != user.PendingEmail {
+		err = cfg.sendEmailVerification(req.Context(), userID, email)
+		if err != nil {
+			log.Printf("Error sending verification email: %s", err)
+		}
 	}
-	responseJSON(resp, 200, respBody)
+
+	responseJSON(resp, 200, userFromDB(updated))
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(resp http.ResponseWriter, req *http.Request) {
@@ -627,40 +811,32 @@ func (cfg *apiConfig) handlerDeleteChirp(resp http.ResponseWriter, req *http.Req
 	chirpUUID, err := uuid.Parse(path)
 	if err != nil {
 		log.Printf("Error parsing ChirpID to UUID: %s", err)
-		resp.WriteHeader(404)
+		responseInvalidID(resp, req, "chirpID")
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetChirp(req.Context(), chirpUUID)
-	if err != nil {
-		log.Printf("Error getting chirp: %s", err)
-		resp.WriteHeader(404)
+	chirp, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: chirpUUID})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
 		return
 	}
-
-	tokenJWT, err := auth.GetBearerToken(req.Header)
 	if err != nil {
-		log.Printf("Error getting Bearer token: %s", err)
-		resp.WriteHeader(401)
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(tokenJWT, cfg.keyJWT)
-	if err != nil {
-		log.Printf("Error validating JWT: %s", err)
-		resp.WriteHeader(401)
-		return
-	}
+	userID := authUserID(req)
 
-	if userID != chirp.UserID {
-		resp.WriteHeader(403)
+	if userID != chirp.Chirp.UserID {
+		responseError(resp, req, 403, errCodeForbidden, "Only the author can delete the chirp")
 		return
 	}
 
 	err = cfg.dbQueries.DeleteChirp(req.Context(), chirpUUID)
 	if err != nil {
 		log.Printf("Error deleting the chirp: %s", err)
-		resp.WriteHeader(500)
+		responseInternalError(resp, req)
 		return
 	}
 
@@ -671,7 +847,7 @@ func (cfg *apiConfig) handlerChirpyRed(resp http.ResponseWriter, req *http.Reque
 	polkaAPI, err := auth.GetAPIKey(req.Header)
 	if err != nil || polkaAPI != cfg.keyPolka {
 		log.Printf("Error getting Polka API key: %s", err)
-		resp.WriteHeader(401)
+		responseError(resp, req, 401, errCodeUnauthorized, "A valid API key is required")
 		return
 	}
 
@@ -687,7 +863,7 @@ func (cfg *apiConfig) handlerChirpyRed(resp http.ResponseWriter, req *http.Reque
 	err = decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		resp.WriteHeader(500)
+		responseInvalidJSON(resp, req)
 		return
 	}
 
@@ -699,14 +875,18 @@ func (cfg *apiConfig) handlerChirpyRed(resp http.ResponseWriter, req *http.Reque
 	userUUID, err := uuid.Parse(params.Data.UserID)
 	if err != nil {
 		log.Printf("Error parsing UserID to UUID: %s", err)
-		resp.WriteHeader(404)
+		responseInvalidID(resp, req, "data.user_id")
 		return
 	}
 
 	_, err = cfg.dbQueries.UpdateChirpyRed(req.Context(), userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+		return
+	}
 	if err != nil {
 		log.Printf("Error upgrading user: %s", err)
-		resp.WriteHeader(404)
+		responseInternalError(resp, req)
 		return
 	}
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxAPITokenNameLength = 100
+
+type APIToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Hint       string     `json:"hint"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Token is only sent when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+func apiTokenFromDB(token database.ApiToken) APIToken {
+	respBody := APIToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		Hint:      token.TokenHint,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt.Valid {
+		respBody.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		respBody.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return respBody
+}
+
+func (cfg *apiConfig) handlerCreateAPIToken(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPITokenNameLength {
+		responseError(resp, req, 400, errCodeInvalidParameter, "Name is required and can have up to 100 characters")
+		return
+	}
+
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			responseError(resp, req, 400, errCodeInvalidParameter, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		log.Printf("Error making API token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	created, err := cfg.dbQueries.CreateAPIToken(req.Context(), database.CreateAPITokenParams{
+		UserID:    authUserID(req),
+		Name:      name,
+		TokenHash: auth.HashRefreshToken(token),
+		TokenHint: auth.APITokenHint(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error creating API token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := apiTokenFromDB(created)
+	respBody.Token = token
+	responseJSON(resp, 201, respBody)
+}
+
+func (cfg *apiConfig) handlerGetAPITokens(resp http.ResponseWriter, req *http.Request) {
+	tokens, err := cfg.dbQueries.GetAPITokens(req.Context(), authUserID(req))
+	if err != nil {
+		log.Printf("Error getting API tokens: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := []APIToken{}
+	for _, token := range tokens {
+		respBody = append(respBody, apiTokenFromDB(token))
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+func (cfg *apiConfig) handlerRevokeAPIToken(resp http.ResponseWriter, req *http.Request) {
+	tokenID, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		responseInvalidID(resp, req, "tokenID")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokeAPIToken(req.Context(), database.RevokeAPITokenParams{
+		ID:     tokenID,
+		UserID: authUserID(req),
+	})
+	if err != nil {
+		log.Printf("Error revoking API token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if revoked == 0 {
+		responseError(resp, req, 404, errCodeAPITokenNotFound, "API token not found")
+		return
+	}
+	resp.WriteHeader(204)
+}
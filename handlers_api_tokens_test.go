@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+)
+
+// createAPIToken creates a token through the handler, logged in with an access token.
+func createAPIToken(t *testing.T, cfg *apiConfig, jwt string, body string) (int, APIToken) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/tokens", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	rec := httptest.NewRecorder()
+	cfg.middlewareAuth(cfg.handlerCreateAPIToken)(rec, req)
+
+	token := APIToken{}
+	json.Unmarshal(rec.Body.Bytes(), &token)
+	return rec.Code, token
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := store.addUser(auth.RoleUser).ID
+	jwt, err := auth.MakeJWT(userID, auth.RoleUser, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	code, created := createAPIToken(t, cfg, jwt, `{"name": "bot", "scopes": ["chirps:read", "chirps:write"]}`)
+	if code != 201 || created.Token == "" {
+		t.Fatalf("handlerCreateAPIToken() status = %d, token %q, want 201 and a token", code, created.Token)
+	}
+	if _, err := store.GetActiveAPIToken(context.Background(), created.Token); err == nil {
+		t.Errorf("API token is stored in plaintext")
+	}
+
+	expired, _ := auth.MakeAPIToken()
+	store.CreateAPIToken(context.Background(), database.CreateAPITokenParams{
+		UserID:    userID,
+		TokenHash: auth.HashRefreshToken(expired),
+		Scopes:    []string{auth.ScopeChirpsWrite},
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+
+	tests := []struct {
+		name       string
+		middleware func(http.HandlerFunc) http.HandlerFunc
+		token      string
+		wantCode   int
+		wantErr    string
+	}{
+		{
+			name:       "API token with the scope",
+			middleware: func(next http.HandlerFunc) http.HandlerFunc { return cfg.middlewareScope(auth.ScopeChirpsWrite, next) },
+			token:      created.Token,
+			wantCode:   200,
+		},
+		{
+			name:       "API token without the scope",
+			middleware: func(next http.HandlerFunc) http.HandlerFunc { return cfg.middlewareScope(auth.ScopeFollowsWrite, next) },
+			token:      created.Token,
+			wantCode:   403,
+			wantErr:    errCodeInsufficientScope,
+		},
+		{
+			name:       "API token on a route without scopes",
+			middleware: cfg.middlewareAuth,
+			token:      created.Token,
+			wantCode:   403,
+			wantErr:    errCodeInsufficientScope,
+		},
+		{
+			name: "API token on an optional auth route",
+			middleware: func(next http.HandlerFunc) http.HandlerFunc {
+				return cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, next)
+			},
+			token:    created.Token,
+			wantCode: 200,
+		},
+		{
+			name:       "Expired API token",
+			middleware: func(next http.HandlerFunc) http.HandlerFunc { return cfg.middlewareScope(auth.ScopeChirpsWrite, next) },
+			token:      expired,
+			wantCode:   401,
+			wantErr:    errCodeUnauthorized,
+		},
+		{
+			name:       "Access token is allowed every scope",
+			middleware: func(next http.HandlerFunc) http.HandlerFunc { return cfg.middlewareScope(auth.ScopeFollowsWrite, next) },
+			token:      jwt,
+			wantCode:   200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := test.middleware(func(resp http.ResponseWriter, req *http.Request) {
+				if authUserID(req) != userID {
+					t.Errorf("authUserID() = %v, want %v", authUserID(req), userID)
+				}
+				resp.WriteHeader(200)
+			})
+			code, body := callWithToken(t, handler, "/api/test", test.token)
+			if code != test.wantCode || body.Error.Code != test.wantErr {
+				t.Errorf("Status = %d, code = %q, want %d %q", code, body.Error.Code, test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerRevokeAPIToken(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := store.addUser(auth.RoleUser).ID
+	jwt, _ := auth.MakeJWT(userID, auth.RoleUser, cfg.jwtKeys, time.Hour)
+	otherJWT, _ := auth.MakeJWT(store.addUser(auth.RoleUser).ID, auth.RoleUser, cfg.jwtKeys, time.Hour)
+	_, created := createAPIToken(t, cfg, jwt, `{"name": "bot", "scopes": ["chirps:write"]}`)
+
+	revoke := func(jwt string) int {
+		req := httptest.NewRequest("DELETE", "/api/tokens/"+created.ID.String(), nil)
+		req.SetPathValue("tokenID", created.ID.String())
+		req.Header.Set("Authorization", "Bearer "+jwt)
+		rec := httptest.NewRecorder()
+		cfg.middlewareAuth(cfg.handlerRevokeAPIToken)(rec, req)
+		return rec.Code
+	}
+
+	if code := revoke(otherJWT); code != 404 {
+		t.Errorf("Revoking the token of another user: status = %d, want 404", code)
+	}
+	if code := revoke(jwt); code != 204 {
+		t.Errorf("handlerRevokeAPIToken() status = %d, want 204", code)
+	}
+
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, func(resp http.ResponseWriter, req *http.Request) {})
+	code, _ := callWithToken(t, handler, "/api/chirps", created.Token)
+	if code != 401 {
+		t.Errorf("Revoked API token: status = %d, want 401", code)
+	}
+}
+
+func TestHandlerCreateAPITokenValidates(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	jwt, _ := auth.MakeJWT(store.addUser(auth.RoleUser).ID, auth.RoleUser, cfg.jwtKeys, time.Hour)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "No name",
+			body: `{"scopes": ["chirps:read"]}`,
+		},
+		{
+			name: "Unknown scope",
+			body: `{"name": "bot", "scopes": ["admin"]}`,
+		},
+		{
+			name: "Expiration in the past",
+			body: `{"name": "bot", "scopes": ["chirps:read"], "expires_at": "2020-01-01T00:00:00Z"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _ := createAPIToken(t, cfg, jwt, test.body)
+			if code != 400 {
+				t.Errorf("handlerCreateAPIToken() status = %d, want 400", code)
+			}
+		})
+	}
+}
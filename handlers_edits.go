@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type ChirpHistory struct {
+	Chirp     Chirp           `json:"chirp"`
+	Revisions []ChirpRevision `json:"revisions"`
+}
+
+func (cfg *apiConfig) handlerEditChirp(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	userID := authUserID(req)
+
+	chirp, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		ID:       chirpUUID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	if userID != chirp.Chirp.UserID {
+		responseError(resp, req, 403, errCodeForbidden, "Only the author can edit the chirp")
+		return
+	}
+
+	if chirp.Chirp.Kind == "rechirp" {
+		responseError(resp, req, 400, errCodeRechirpNotEditable, "Rechirps cannot be edited")
+		return
+	}
+
+	if time.Now().UTC().Sub(chirp.Chirp.CreatedAt) > cfg.chirpEditWindow {
+		responseError(resp, req, 403, errCodeEditWindowExpired, "Chirps can only be edited within "+cfg.chirpEditWindow.String()+" after posting")
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	filtered, ok := cfg.checkChirpBody(resp, req, userID, params.Body)
+	if !ok {
+		return
+	}
+
+	edited, err := cfg.dbQueries.EditChirp(req.Context(), database.EditChirpParams{
+		ID:   chirpUUID,
+		Body: filtered.Text,
+	})
+	if err != nil {
+		log.Printf("Error editing chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	cfg.flagChirp(req.Context(), edited.ID, filtered.Flagged)
+
+	respBody := chirpFromDB(edited, chirp.LikeCount, chirp.LikedByMe, chirp.ReplyCount)
+	err = cfg.attachReferences(req.Context(), false, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+func (cfg *apiConfig) handlerGetChirpHistory(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	viewerID := authViewerID(req)
+	includeHidden := authCanSeeHidden(req)
+
+	chirp, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{
+		ViewerID:      viewerID,
+		ID:            chirpUUID,
+		IncludeHidden: includeHidden,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	revisions, err := cfg.dbQueries.GetChirpRevisions(req.Context(), chirpUUID)
+	if err != nil {
+		log.Printf("Error getting chirp revisions: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	history := ChirpHistory{
+		Chirp:     chirpFromDB(chirp.Chirp, chirp.LikeCount, chirp.LikedByMe, chirp.ReplyCount),
+		Revisions: make([]ChirpRevision, len(revisions)),
+	}
+	for i, revision := range revisions {
+		history.Revisions[i] = ChirpRevision{
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		}
+	}
+	err = cfg.attachReferences(req.Context(), includeHidden, []*Chirp{&history.Chirp})
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, history)
+}
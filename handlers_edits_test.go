@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerEditChirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerEditChirp)
+	author := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+	chirp := store.addChirp(author.ID)
+	old := store.addChirp(author.ID)
+	old.CreatedAt = time.Now().UTC().Add(-time.Hour)
+	store.chirps[old.ID] = old
+	rechirp, err := store.CreateRechirp(context.Background(), database.CreateRechirpParams{
+		UserID:      author.ID,
+		ReferenceID: uuid.NullUUID{UUID: store.addChirp(other.ID).ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateRechirp() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		user     database.User
+		chirpID  string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Edit",
+			user:     author,
+			chirpID:  chirp.ID.String(),
+			body:     "Edited",
+			wantCode: 200,
+		},
+		{
+			name:     "Someone else's chirp",
+			user:     other,
+			chirpID:  chirp.ID.String(),
+			body:     "Mine now",
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Rechirp",
+			user:     author,
+			chirpID:  rechirp.ID.String(),
+			body:     "Edited",
+			wantCode: 400,
+			wantErr:  errCodeRechirpNotEditable,
+		},
+		{
+			name:     "Edit window passed",
+			user:     author,
+			chirpID:  old.ID.String(),
+			body:     "Too late",
+			wantCode: 403,
+			wantErr:  errCodeEditWindowExpired,
+		},
+		{
+			name:     "Too long",
+			user:     author,
+			chirpID:  chirp.ID.String(),
+			body:     strings.Repeat("a", 141),
+			wantCode: 400,
+			wantErr:  errCodeChirpTooLong,
+		},
+		{
+			name:     "Unknown chirp",
+			user:     author,
+			chirpID:  uuid.New().String(),
+			body:     "Edited",
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			user:     author,
+			chirpID:  "chirp",
+			body:     "Edited",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, test.user, "chirpID", test.chirpID, `{"body": "`+test.body+`"}`)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerEditChirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if code != 200 {
+				return
+			}
+			edited := Chirp{}
+			json.Unmarshal(rec.Body.Bytes(), &edited)
+			if edited.Body != test.body || !edited.Edited {
+				t.Errorf("Chirp body = %q, edited = %v, want %q and edited", edited.Body, edited.Edited, test.body)
+			}
+		})
+	}
+}
+
+func TestHandlerGetChirpHistory(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	edit := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerEditChirp)
+	history := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, cfg.handlerGetChirpHistory)
+	author := store.addUser(auth.RoleUser)
+	chirp := store.addChirp(author.ID)
+	unedited := store.addChirp(author.ID)
+	for _, body := range []string{"Second", "Third"} {
+		code, _ := callAsUser(t, cfg, edit, author, "chirpID", chirp.ID.String(), `{"body": "`+body+`"}`)
+		if code != 200 {
+			t.Fatalf("handlerEditChirp() status = %d, want 200", code)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		chirpID       uuid.UUID
+		wantCode      int
+		wantBody      string
+		wantRevisions []string
+	}{
+		{
+			name:          "Edited chirp",
+			chirpID:       chirp.ID,
+			wantCode:      200,
+			wantBody:      "Third",
+			wantRevisions: []string{"Second", "Chirp"},
+		},
+		{
+			name:          "Chirp without edits",
+			chirpID:       unedited.ID,
+			wantCode:      200,
+			wantBody:      "Chirp",
+			wantRevisions: []string{},
+		},
+		{
+			name:     "Unknown chirp",
+			chirpID:  uuid.New(),
+			wantCode: 404,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, history, author, "chirpID", test.chirpID.String(), "")
+			if code != test.wantCode {
+				t.Fatalf("handlerGetChirpHistory() status = %d, want %d", code, test.wantCode)
+			}
+			if code != 200 {
+				return
+			}
+			got := ChirpHistory{}
+			json.Unmarshal(rec.Body.Bytes(), &got)
+			if got.Chirp.Body != test.wantBody {
+				t.Errorf("Chirp body = %q, want %q", got.Chirp.Body, test.wantBody)
+			}
+			bodies := []string{}
+			for _, revision := range got.Revisions {
+				bodies = append(bodies, revision.Body)
+			}
+			if !slices.Equal(bodies, test.wantRevisions) {
+				t.Errorf("Revisions = %q, want newest first %q", bodies, test.wantRevisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const emailVerificationExpiration = 24 * time.Hour
+
+// parseEmail accepts a bare address like user@example.com, without a display name.
+func parseEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", errors.New("Email is required")
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", errors.New("Email is not a valid address")
+	}
+	if !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "", errors.New("Email domain must have a dot")
+	}
+	return email, nil
+}
+
+// sendEmailVerification emails a token that confirms the address for the user.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.dbQueries.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationExpiration),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := token
+	if cfg.emailVerificationURL != "" {
+		link = cfg.emailVerificationURL + token
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email",
+		Body: fmt.Sprintf("To confirm that %s is your email, use this within %s:\n\n%s\n\n"+
+			"If you did not sign up for Chirpy or change your email, ignore this email.\n",
+			email, emailVerificationExpiration, link),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	token, err := cfg.dbQueries.UseEmailVerificationToken(req.Context(), auth.HashRefreshToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 400, errCodeInvalidVerificationToken, "Verification token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		log.Printf("Error using verification token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	user, err := cfg.dbQueries.ConfirmEmail(req.Context(), database.ConfirmEmailParams{
+		Email: sql.NullString{String: token.Email, Valid: true},
+		ID:    token.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 400, errCodeInvalidVerificationToken, "Verification token is for an email that is not used anymore")
+		return
+	}
+	if isUniqueViolation(err) {
+		responseError(resp, req, 409, errCodeEmailTaken, "A user with this email already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error confirming email: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 200, userFromDB(user))
+}
+
+// handlerResendVerification sends a new token for the pending email, or for the current one if it is not verified yet.
+func (cfg *apiConfig) handlerResendVerification(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	email := user.PendingEmail.String
+	if !user.PendingEmail.Valid {
+		if user.EmailVerifiedAt.Valid {
+			responseError(resp, req, 409, errCodeEmailAlreadyVerified, "Email is already verified")
+			return
+		}
+		email = user.Email.String
+	}
+
+	err = cfg.sendEmailVerification(req.Context(), user.ID, email)
+	if err != nil {
+		log.Printf("Error sending verification email: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(202)
+}
+
+// checkCanPost lets users without a verified email post only UNVERIFIED_DAILY_CHIRPS chirps a day,
+// writing a 403 response when the limit is reached.
+func (cfg *apiConfig) checkCanPost(resp http.ResponseWriter, req *http.Request, userID uuid.UUID) bool {
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return false
+	}
+	if user.EmailVerifiedAt.Valid {
+		return true
+	}
+
+	var posted int64
+	if cfg.unverifiedDailyChirps > 0 {
+		posted, err = cfg.dbQueries.CountUserChirpsSince(req.Context(), database.CountUserChirpsSinceParams{
+			UserID:    userID,
+			CreatedAt: time.Now().UTC().Add(-24 * time.Hour),
+		})
+		if err != nil {
+			log.Printf("Error counting chirps: %s", err)
+			responseInternalError(resp, req)
+			return false
+		}
+	}
+	if posted < int64(cfg.unverifiedDailyChirps) {
+		return true
+	}
+
+	type details struct {
+		DailyLimit int `json:"daily_limit"`
+	}
+	message := "Verify your email to post more chirps"
+	if cfg.unverifiedDailyChirps == 0 {
+		message = "Verify your email to post chirps"
+	}
+	responseErrorDetails(resp, req, 403, errCodeEmailNotVerified, message, details{DailyLimit: cfg.unverifiedDailyChirps})
+	return false
+}
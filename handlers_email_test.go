@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+)
+
+func TestParseEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Plain address",
+			email: "user@example.com",
+			want:  "user@example.com",
+		},
+		{
+			name:  "Spaces around",
+			email: "  user@example.com ",
+			want:  "user@example.com",
+		},
+		{
+			name:  "Plus and subdomain",
+			email: "user+chirpy@mail.example.co.uk",
+			want:  "user+chirpy@mail.example.co.uk",
+		},
+		{
+			name:    "Empty",
+			email:   "",
+			wantErr: true,
+		},
+		{
+			name:    "No at sign",
+			email:   "user.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "Display name",
+			email:   "User <user@example.com>",
+			wantErr: true,
+		},
+		{
+			name:    "Domain without a dot",
+			email:   "user@localhost",
+			wantErr: true,
+		},
+		{
+			name:    "Two addresses",
+			email:   "user@example.com, other@example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseEmail(test.email)
+			if (err != nil) != test.wantErr {
+				t.Errorf("parseEmail() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("parseEmail() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// newEmailTestConfig adds a user with the email user@example.com, which is not verified yet,
+// and the password "password".
+func newEmailTestConfig(t *testing.T) (*apiConfig, *memoryStore, database.User) {
+	t.Helper()
+	cfg, store := newTestConfig(t)
+	cfg.emailVerificationURL = "https://chirpy.example.com/verify?token="
+	hash, err := cfg.passwordHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	user := store.addUser(auth.RoleUser)
+	user.Email = sql.NullString{String: "user@example.com", Valid: true}
+	user.EmailVerifiedAt = sql.NullTime{}
+	user.HashedPassword = hash
+	store.setUser(user)
+	return cfg, store, user
+}
+
+var verifyTokenPattern = regexp.MustCompile(`verify\?token=([0-9a-f]+)`)
+
+// nextVerifyToken waits for the next verification mail and returns its token.
+func nextVerifyToken(t *testing.T, cfg *apiConfig, to string) string {
+	t.Helper()
+	msg := cfg.mailer.(*memoryMailer).next(t)
+	match := verifyTokenPattern.FindStringSubmatch(msg.Body)
+	if match == nil || msg.To != to {
+		t.Fatalf("Mail has no verification link for %s: %+v", to, msg)
+	}
+	return match[1]
+}
+
+func callVerifyEmail(t *testing.T, cfg *apiConfig, token string) (int, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/users/verify", strings.NewReader(`{"token":"`+token+`"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerVerifyEmail(rec, req)
+	return rec.Code, rec
+}
+
+func callUpdateUser(t *testing.T, cfg *apiConfig, user database.User, email string) User {
+	t.Helper()
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerUpdateUser), user, "", "", `{"email":"`+email+`","password":"password"}`)
+	if code != 200 {
+		t.Fatalf("handlerUpdateUser() status = %d, want 200: %s", code, rec.Body.String())
+	}
+	body := User{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body
+}
+
+func TestHandlerVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		// token makes the token to verify with, after the user asked to verify user@example.com.
+		token     func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string
+		wantCode  int
+		wantErr   string
+		wantEmail string
+	}{
+		{
+			name: "Sent token",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				return sent
+			},
+			wantCode:  200,
+			wantEmail: "user@example.com",
+		},
+		{
+			name: "Reused token",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				if code, _ := callVerifyEmail(t, cfg, sent); code != 200 {
+					t.Fatalf("handlerVerifyEmail() status = %d, want 200", code)
+				}
+				return sent
+			},
+			wantCode: 400,
+			wantErr:  errCodeInvalidVerificationToken,
+		},
+		{
+			name: "Expired token",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				store.CreateEmailVerificationToken(context.Background(), database.CreateEmailVerificationTokenParams{
+					TokenHash: auth.HashRefreshToken("expired"),
+					UserID:    user.ID,
+					Email:     "user@example.com",
+					ExpiresAt: time.Now().UTC().Add(-time.Minute),
+				})
+				return "expired"
+			},
+			wantCode: 400,
+			wantErr:  errCodeInvalidVerificationToken,
+		},
+		{
+			name: "Unknown token",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				return "unknown"
+			},
+			wantCode: 400,
+			wantErr:  errCodeInvalidVerificationToken,
+		},
+		{
+			name: "Token of an email that was changed since",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				user.Email = sql.NullString{String: "new@example.com", Valid: true}
+				store.setUser(user)
+				return sent
+			},
+			wantCode: 400,
+			wantErr:  errCodeInvalidVerificationToken,
+		},
+		{
+			name: "Email taken by another user meanwhile",
+			token: func(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, sent string) string {
+				user.Email = sql.NullString{String: "old@example.com", Valid: true}
+				user.PendingEmail = sql.NullString{String: "user@example.com", Valid: true}
+				store.setUser(user)
+				other := store.addUser(auth.RoleUser)
+				other.Email = sql.NullString{String: "user@example.com", Valid: true}
+				store.setUser(other)
+				return sent
+			},
+			wantCode: 409,
+			wantErr:  errCodeEmailTaken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store, user := newEmailTestConfig(t)
+			err := cfg.sendEmailVerification(context.Background(), user.ID, "user@example.com")
+			if err != nil {
+				t.Fatalf("sendEmailVerification() error = %v", err)
+			}
+			sent := nextVerifyToken(t, cfg, "user@example.com")
+			if _, ok := store.verifyTokens[sent]; ok {
+				t.Errorf("Verification token is stored in plaintext")
+			}
+
+			code, rec := callVerifyEmail(t, cfg, test.token(t, cfg, store, user, sent))
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerVerifyEmail() = %d %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if code != 200 {
+				return
+			}
+			got := store.user(user.ID)
+			if got.Email.String != test.wantEmail || !got.EmailVerifiedAt.Valid || got.PendingEmail.Valid {
+				t.Errorf("User email = %q, verified %v, pending %q, want %q verified", got.Email.String, got.EmailVerifiedAt.Valid, got.PendingEmail.String, test.wantEmail)
+			}
+		})
+	}
+}
+
+func TestHandlerUpdateUserPendingEmail(t *testing.T) {
+	cfg, store, user := newEmailTestConfig(t)
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	store.setUser(user)
+	mail := cfg.mailer.(*memoryMailer)
+
+	body := callUpdateUser(t, cfg, user, "new@example.com")
+	if body.Email != "user@example.com" || body.PendingEmail != "new@example.com" || !body.EmailVerified {
+		t.Errorf("User = %+v, want the old verified email and new@example.com pending", body)
+	}
+	first := nextVerifyToken(t, cfg, "new@example.com")
+
+	callUpdateUser(t, cfg, user, "new@example.com")
+	if len(mail.sent) != 0 {
+		t.Errorf("The same pending email was sent another verification mail")
+	}
+
+	body = callUpdateUser(t, cfg, user, "other@example.com")
+	if body.PendingEmail != "other@example.com" {
+		t.Errorf("Pending email = %q, want the replacing other@example.com", body.PendingEmail)
+	}
+	second := nextVerifyToken(t, cfg, "other@example.com")
+
+	code, rec := callVerifyEmail(t, cfg, first)
+	if code != 400 || errorCode(rec) != errCodeInvalidVerificationToken {
+		t.Errorf("Token of the replaced email: %d %q, want 400 %q", code, errorCode(rec), errCodeInvalidVerificationToken)
+	}
+	code, _ = callVerifyEmail(t, cfg, second)
+	if got := store.user(user.ID); code != 200 || got.Email.String != "other@example.com" || got.PendingEmail.Valid {
+		t.Fatalf("Confirming other@example.com: status = %d, email %q, pending %q", code, got.Email.String, got.PendingEmail.String)
+	}
+
+	callUpdateUser(t, cfg, user, "third@example.com")
+	nextVerifyToken(t, cfg, "third@example.com")
+	body = callUpdateUser(t, cfg, user, "other@example.com")
+	if body.Email != "other@example.com" || body.PendingEmail != "" {
+		t.Errorf("User = %+v, want the current email with the pending one cancelled", body)
+	}
+
+	taken := store.addUser(auth.RoleUser)
+	taken.Email = sql.NullString{String: "taken@example.com", Valid: true}
+	store.setUser(taken)
+	code, rec = callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerUpdateUser), user, "", "", `{"email":"taken@example.com","password":"password"}`)
+	if code != 409 || errorCode(rec) != errCodeEmailTaken {
+		t.Errorf("Email of another user: %d %q, want 409 %q", code, errorCode(rec), errCodeEmailTaken)
+	}
+}
+
+func TestHandlerResendVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		pending  string
+		wantCode int
+		wantErr  string
+		wantTo   string
+	}{
+		{
+			name:     "Unverified email",
+			wantCode: 202,
+			wantTo:   "user@example.com",
+		},
+		{
+			name:     "Pending email",
+			verified: true,
+			pending:  "new@example.com",
+			wantCode: 202,
+			wantTo:   "new@example.com",
+		},
+		{
+			name:     "Pending email of an unverified user",
+			pending:  "new@example.com",
+			wantCode: 202,
+			wantTo:   "new@example.com",
+		},
+		{
+			name:     "Verified email",
+			verified: true,
+			wantCode: 409,
+			wantErr:  errCodeEmailAlreadyVerified,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store, user := newEmailTestConfig(t)
+			if test.verified {
+				user.EmailVerifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+			}
+			if test.pending != "" {
+				user.PendingEmail = sql.NullString{String: test.pending, Valid: true}
+			}
+			store.setUser(user)
+
+			code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerResendVerification), user, "", "", "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerResendVerification() = %d %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if test.wantTo == "" {
+				if len(cfg.mailer.(*memoryMailer).sent) != 0 {
+					t.Errorf("A verification mail was sent")
+				}
+				return
+			}
+
+			token := nextVerifyToken(t, cfg, test.wantTo)
+			if code, _ := callVerifyEmail(t, cfg, token); code != 200 || store.user(user.ID).Email.String != test.wantTo {
+				t.Errorf("Verifying the resent token: status = %d, email %q, want 200 %q", code, store.user(user.ID).Email.String, test.wantTo)
+			}
+		})
+	}
+}
+
+func TestCheckCanPost(t *testing.T) {
+	tests := []struct {
+		name       string
+		verified   bool
+		dailyLimit int
+		// posted are how long ago the user posted their chirps; deleted ones are negative.
+		posted   []time.Duration
+		wantCode int
+	}{
+		{
+			name:     "Verified user",
+			verified: true,
+			posted:   []time.Duration{time.Minute, time.Minute},
+			wantCode: 201,
+		},
+		{
+			name:     "Unverified user without a daily limit",
+			wantCode: 403,
+		},
+		{
+			name:       "Unverified user under the daily limit",
+			dailyLimit: 2,
+			posted:     []time.Duration{time.Minute},
+			wantCode:   201,
+		},
+		{
+			name:       "Unverified user at the daily limit",
+			dailyLimit: 2,
+			posted:     []time.Duration{time.Minute, time.Hour},
+			wantCode:   403,
+		},
+		{
+			name:       "Chirps older than a day",
+			dailyLimit: 2,
+			posted:     []time.Duration{time.Minute, 25 * time.Hour},
+			wantCode:   201,
+		},
+		{
+			name:       "Deleted chirps count",
+			dailyLimit: 2,
+			posted:     []time.Duration{time.Minute, -time.Hour},
+			wantCode:   403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store, user := newEmailTestConfig(t)
+			cfg.unverifiedDailyChirps = test.dailyLimit
+			if test.verified {
+				user.EmailVerifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+				store.setUser(user)
+			}
+			for _, ago := range test.posted {
+				chirp := store.addChirp(user.ID)
+				if ago < 0 {
+					ago = -ago
+					chirp.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+				}
+				chirp.CreatedAt = time.Now().UTC().Add(-ago)
+				store.chirps[chirp.ID] = chirp
+			}
+
+			_, rec := postChirp(t, cfg, user, `{"body":"Hello"}`)
+			if rec.Code != test.wantCode {
+				t.Fatalf("handlerChirps() status = %d, want %d: %s", rec.Code, test.wantCode, rec.Body.String())
+			}
+			if rec.Code != 403 {
+				return
+			}
+			body := struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details struct {
+						DailyLimit int `json:"daily_limit"`
+					} `json:"details"`
+				} `json:"error"`
+			}{}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Error.Code != errCodeEmailNotVerified || body.Error.Details.DailyLimit != test.dailyLimit {
+				t.Errorf("Error = %+v, want %q with the daily limit %d", body.Error, errCodeEmailNotVerified, test.dailyLimit)
+			}
+		})
+	}
+}
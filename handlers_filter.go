@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/contentfilter"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type FilteredWord struct {
+	Word   string `json:"word"`
+	Policy string `json:"policy"`
+}
+
+type FlaggedChirp struct {
+	Chirp     Chirp     `json:"chirp"`
+	Words     []string  `json:"words"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+type FlaggedChirpsPage struct {
+	FlaggedChirps []FlaggedChirp `json:"flagged_chirps"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+// loadContentFilter builds the filter from the filtered_words table and the optional word list file.
+// Words from the table win, so a policy changed or a word removed through the admin endpoints survives a restart.
+func loadContentFilter(dbQueries store, path string) (*contentfilter.Filter, error) {
+	words := map[string]contentfilter.Policy{}
+	if path != "" {
+		fileWords, err := contentfilter.LoadFile(path)
+		if err != nil {
+			return contentfilter.New(words), err
+		}
+		words = fileWords
+	}
+
+	dbWords, err := dbQueries.GetFilteredWords(context.Background())
+	if err != nil {
+		return contentfilter.New(words), err
+	}
+	for _, word := range dbWords {
+		if word.Disabled {
+			delete(words, word.Word)
+			continue
+		}
+		policy, err := contentfilter.ParsePolicy(word.Policy)
+		if err != nil {
+			log.Printf("Skipping filtered word %q: %s", word.Word, err)
+			continue
+		}
+		words[word.Word] = policy
+	}
+
+	return contentfilter.New(words), nil
+}
+
+func (cfg *apiConfig) handlerGetFilteredWords(resp http.ResponseWriter, req *http.Request) {
+	words := []FilteredWord{}
+	for word, policy := range cfg.contentFilter.Words() {
+		words = append(words, FilteredWord{
+			Word:   word,
+			Policy: string(policy),
+		})
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Word < words[j].Word })
+	responseJSON(resp, 200, words)
+}
+
+func (cfg *apiConfig) handlerSetFilteredWord(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Policy string `json:"policy"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	word, err := contentfilter.ParseWord(req.PathValue("word"))
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	policy, err := contentfilter.ParsePolicy(params.Policy)
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	saved, err := cfg.dbQueries.UpsertFilteredWord(req.Context(), database.UpsertFilteredWordParams{
+		Word:   word,
+		Policy: string(policy),
+	})
+	if err != nil {
+		log.Printf("Error saving filtered word: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	cfg.contentFilter.Set(saved.Word, policy)
+
+	respBody := FilteredWord{
+		Word:   saved.Word,
+		Policy: saved.Policy,
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+func (cfg *apiConfig) handlerDeleteFilteredWord(resp http.ResponseWriter, req *http.Request) {
+	word, err := contentfilter.ParseWord(req.PathValue("word"))
+	if err != nil {
+		responseError(resp, req, 404, errCodeWordNotFound, "Word is not filtered")
+		return
+	}
+
+	policy, ok := cfg.contentFilter.Words()[word]
+	if !ok {
+		responseError(resp, req, 404, errCodeWordNotFound, "Word is not filtered")
+		return
+	}
+
+	err = cfg.dbQueries.DisableFilteredWord(req.Context(), database.DisableFilteredWordParams{
+		Word:   word,
+		Policy: string(policy),
+	})
+	if err != nil {
+		log.Printf("Error deleting filtered word: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	cfg.contentFilter.Remove(word)
+
+	resp.WriteHeader(204)
+}
+
+// handlerGetFlaggedChirps lists the chirps the content filter flagged for review, oldest first.
+func (cfg *apiConfig) handlerGetFlaggedChirps(resp http.ResponseWriter, req *http.Request) {
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	// one extra row tells us whether there is a next page
+	rows, err := cfg.dbQueries.GetFlaggedChirps(req.Context(), database.GetFlaggedChirpsParams{
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting flagged chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	page := FlaggedChirpsPage{FlaggedChirps: []FlaggedChirp{}}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = pagination.EncodeCursor(last.FlaggedAt, last.Chirp.ID)
+	}
+	for _, row := range rows {
+		page.FlaggedChirps = append(page.FlaggedChirps, FlaggedChirp{
+			Chirp:     chirpFromDB(row.Chirp, 0, false, 0),
+			Words:     row.Words,
+			FlaggedAt: row.FlaggedAt,
+		})
+	}
+
+	responseJSON(resp, 200, page)
+}
+
+// handlerDeleteFlaggedChirp clears the flag once a moderator has reviewed the chirp.
+func (cfg *apiConfig) handlerDeleteFlaggedChirp(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteFlaggedChirp(req.Context(), chirpUUID)
+	if err != nil {
+		log.Printf("Error deleting flagged chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if deleted == 0 {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp is not flagged")
+		return
+	}
+
+	resp.WriteHeader(204)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/contentfilter"
+	"github.com/google/uuid"
+)
+
+func TestHandlerDeleteFilteredWordSurvivesRestart(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	path := filepath.Join(t.TempDir(), "filtered_words.txt")
+	err := os.WriteFile(path, []byte("kerfuffle mask\nsharbert reject\n"), 0o644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cfg.contentFilter, err = loadContentFilter(store, path)
+	if err != nil {
+		t.Fatalf("loadContentFilter() error = %v", err)
+	}
+	moderator := store.addUser(auth.RoleModerator)
+	set := cfg.middlewareRole(auth.RoleModerator, cfg.handlerSetFilteredWord)
+	remove := cfg.middlewareRole(auth.RoleModerator, cfg.handlerDeleteFilteredWord)
+
+	steps := []struct {
+		name      string
+		remove    bool
+		word      string
+		wantCode  int
+		wantErr   string
+		wantWords map[string]contentfilter.Policy
+	}{
+		{
+			name:      "Remove a word of the file",
+			remove:    true,
+			word:      "kerfuffle",
+			wantCode:  204,
+			wantWords: map[string]contentfilter.Policy{"sharbert": contentfilter.PolicyReject},
+		},
+		{
+			name:      "Remove it again",
+			remove:    true,
+			word:      "kerfuffle",
+			wantCode:  404,
+			wantErr:   errCodeWordNotFound,
+			wantWords: map[string]contentfilter.Policy{"sharbert": contentfilter.PolicyReject},
+		},
+		{
+			name:     "Add it back",
+			word:     "kerfuffle",
+			wantCode: 200,
+			wantWords: map[string]contentfilter.Policy{
+				"kerfuffle": contentfilter.PolicyFlag,
+				"sharbert":  contentfilter.PolicyReject,
+			},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			handler := set
+			if step.remove {
+				handler = remove
+			}
+			code, rec := callAsUser(t, cfg, handler, moderator, "word", step.word, `{"policy": "flag"}`)
+			if code != step.wantCode || errorCode(rec) != step.wantErr {
+				t.Fatalf("Status = %d, code = %q, want %d %q", code, errorCode(rec), step.wantCode, step.wantErr)
+			}
+			if got := cfg.contentFilter.Words(); !maps.Equal(got, step.wantWords) {
+				t.Errorf("Words = %v, want %v", got, step.wantWords)
+			}
+
+			restarted, err := loadContentFilter(store, path)
+			if err != nil {
+				t.Fatalf("loadContentFilter() error = %v", err)
+			}
+			if got := restarted.Words(); !maps.Equal(got, step.wantWords) {
+				t.Errorf("Words after a restart = %v, want %v", got, step.wantWords)
+			}
+		})
+	}
+}
+
+func TestHandlerFlaggedChirps(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	cfg.contentFilter.Set("fornax", contentfilter.PolicyFlag)
+	get := cfg.middlewareRole(auth.RoleModerator, cfg.handlerGetFlaggedChirps)
+	remove := cfg.middlewareRole(auth.RoleModerator, cfg.handlerDeleteFlaggedChirp)
+	author := store.addUser(auth.RoleUser)
+	moderator := store.addUser(auth.RoleModerator)
+
+	flagged, _ := postChirp(t, cfg, author, `{"body": "Fornax is here"}`)
+	postChirp(t, cfg, author, `{"body": "Nothing to see"}`)
+	deleted, _ := postChirp(t, cfg, author, `{"body": "Fornax again"}`)
+	chirp := store.chirps[deleted.ID]
+	chirp.DeletedAt = sql.NullTime{Time: store.now(), Valid: true}
+	store.chirps[deleted.ID] = chirp
+
+	code, rec := callAsUser(t, cfg, get, author, "", "", "")
+	if code != 403 || errorCode(rec) != errCodeForbidden {
+		t.Errorf("handlerGetFlaggedChirps() of a user status = %d, code = %q, want 403 %q", code, errorCode(rec), errCodeForbidden)
+	}
+
+	code, rec = callAsUser(t, cfg, get, moderator, "", "", "")
+	if code != 200 {
+		t.Fatalf("handlerGetFlaggedChirps() status = %d, want 200", code)
+	}
+	page := FlaggedChirpsPage{}
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	if len(page.FlaggedChirps) != 1 || page.FlaggedChirps[0].Chirp.ID != flagged.ID {
+		t.Fatalf("Flagged chirps = %+v, want only %v", page.FlaggedChirps, flagged.ID)
+	}
+	if words := page.FlaggedChirps[0].Words; len(words) != 1 || words[0] != "fornax" {
+		t.Errorf("Words = %v, want [fornax]", words)
+	}
+
+	tests := []struct {
+		name     string
+		chirpID  string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Clear the flag",
+			chirpID:  flagged.ID.String(),
+			wantCode: 204,
+		},
+		{
+			name:     "Clear it again",
+			chirpID:  flagged.ID.String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Chirp that is not flagged",
+			chirpID:  uuid.New().String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			chirpID:  "chirp",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, remove, moderator, "chirpID", test.chirpID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerDeleteFlaggedChirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
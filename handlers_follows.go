@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FollowsPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerFollow(resp http.ResponseWriter, req *http.Request) {
+	followeeID, ok := cfg.followTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(req)
+
+	if userID == followeeID {
+		responseError(resp, req, 400, errCodeCannotFollowSelf, "Users cannot follow themselves")
+		return
+	}
+
+	err := cfg.dbQueries.CreateFollow(req.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Error following user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnfollow(resp http.ResponseWriter, req *http.Request) {
+	followeeID, ok := cfg.followTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(req)
+
+	err := cfg.dbQueries.DeleteFollow(req.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Error unfollowing user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(resp http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.followTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetFollowers(req.Context(), database.GetFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting followers: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	follows := make([]Follow, len(rows))
+	for i, row := range rows {
+		follows[i] = Follow{
+			UserID:    row.FollowerID,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	responseJSON(resp, 200, followsPage(follows, limit))
+}
+
+func (cfg *apiConfig) handlerGetFollowing(resp http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.followTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetFollowing(req.Context(), database.GetFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting followed users: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	follows := make([]Follow, len(rows))
+	for i, row := range rows {
+		follows[i] = Follow{
+			UserID:    row.FolloweeID,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	responseJSON(resp, 200, followsPage(follows, limit))
+}
+
+func (cfg *apiConfig) handlerTimeline(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	includeHidden := authCanSeeHidden(req)
+
+	rows, err := cfg.dbQueries.GetTimeline(req.Context(), database.GetTimelineParams{
+		ViewerID:        uuid.NullUUID{UUID: userID, Valid: true},
+		UserID:          userID,
+		IncludeHidden:   includeHidden,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting timeline: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	chirps := make([]Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount)
+	}
+	page := chirpsPage(chirps, limit)
+	err = cfg.attachReferences(req.Context(), includeHidden, chirpPointers(page.Chirps))
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, page)
+}
+
+// followTarget resolves the {userID} path value to an existing user, writing 404 otherwise.
+func (cfg *apiConfig) followTarget(resp http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("Error parsing UserID to UUID: %s", err)
+		responseInvalidID(resp, req, "userID")
+		return uuid.Nil, false
+	}
+
+	_, err = cfg.dbQueries.GetUser(req.Context(), userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+		return uuid.Nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
+
+func followsPage(follows []Follow, limit int32) FollowsPage {
+	if len(follows) <= int(limit) {
+		return FollowsPage{Users: follows}
+	}
+
+	follows = follows[:limit]
+	last := follows[len(follows)-1]
+	return FollowsPage{
+		Users:      follows,
+		NextCursor: pagination.EncodeCursor(last.CreatedAt, last.UserID),
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerFollow(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handlerFollow)
+	follower := store.addUser(auth.RoleUser)
+	followee := store.addUser(auth.RoleUser)
+
+	tests := []struct {
+		name     string
+		userID   string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Follow",
+			userID:   followee.ID.String(),
+			wantCode: 204,
+		},
+		{
+			name:     "Follow again",
+			userID:   followee.ID.String(),
+			wantCode: 204,
+		},
+		{
+			name:     "Follow yourself",
+			userID:   follower.ID.String(),
+			wantCode: 400,
+			wantErr:  errCodeCannotFollowSelf,
+		},
+		{
+			name:     "Unknown user",
+			userID:   uuid.New().String(),
+			wantCode: 404,
+			wantErr:  errCodeUserNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			userID:   "someone",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, follower, "userID", test.userID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerFollow() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+
+	if len(store.follows) != 1 || store.follows[0].FollowerID != follower.ID || store.follows[0].FolloweeID != followee.ID {
+		t.Errorf("Store has follows %+v, want one of the followee", store.follows)
+	}
+}
+
+// follow makes the follower follow the followee through the handler.
+func follow(t *testing.T, cfg *apiConfig, follower database.User, followee uuid.UUID) {
+	t.Helper()
+	code, _ := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerFollow), follower, "userID", followee.String(), "")
+	if code != 204 {
+		t.Fatalf("handlerFollow() status = %d, want 204", code)
+	}
+}
+
+func TestHandlerGetFollows(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	followee := store.addUser(auth.RoleUser)
+	first := store.addUser(auth.RoleUser)
+	second := store.addUser(auth.RoleUser)
+	follow(t, cfg, first, followee.ID)
+	follow(t, cfg, second, followee.ID)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		userID  uuid.UUID
+		want    []uuid.UUID
+	}{
+		{
+			name:    "Followers newest first",
+			handler: cfg.handlerGetFollowers,
+			userID:  followee.ID,
+			want:    []uuid.UUID{second.ID, first.ID},
+		},
+		{
+			name:    "Following",
+			handler: cfg.handlerGetFollowing,
+			userID:  first.ID,
+			want:    []uuid.UUID{followee.ID},
+		},
+		{
+			name:    "Nobody followed",
+			handler: cfg.handlerGetFollowing,
+			userID:  followee.ID,
+			want:    []uuid.UUID{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, test.handler, followee, "userID", test.userID.String(), "")
+			if code != 200 {
+				t.Fatalf("Status = %d, want 200", code)
+			}
+			page := FollowsPage{}
+			json.Unmarshal(rec.Body.Bytes(), &page)
+			got := []uuid.UUID{}
+			for _, user := range page.Users {
+				got = append(got, user.UserID)
+			}
+			if !equalIDs(got, test.want) {
+				t.Errorf("Users = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandlerTimeline(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeChirpsRead, cfg.handlerTimeline)
+	reader := store.addUser(auth.RoleUser)
+	followed := store.addUser(auth.RoleUser)
+	stranger := store.addUser(auth.RoleUser)
+	older := store.addChirp(followed.ID)
+	store.addChirp(stranger.ID)
+	newer := store.addChirp(followed.ID)
+	store.addChirp(reader.ID)
+
+	timeline := func() []uuid.UUID {
+		t.Helper()
+		code, rec := callAsUser(t, cfg, handler, reader, "", "", "")
+		if code != 200 {
+			t.Fatalf("handlerTimeline() status = %d, want 200", code)
+		}
+		page := ChirpsPage{}
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		return chirpIDs(page.Chirps)
+	}
+
+	if got := timeline(); len(got) != 0 {
+		t.Errorf("Timeline without follows = %v, want empty", got)
+	}
+
+	follow(t, cfg, reader, followed.ID)
+	if got, want := timeline(), []uuid.UUID{newer.ID, older.ID}; !equalIDs(got, want) {
+		t.Errorf("Timeline = %v, want the followed user's chirps %v", got, want)
+	}
+
+	code, _ := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerUnfollow), reader, "userID", followed.ID.String(), "")
+	if code != 204 {
+		t.Fatalf("handlerUnfollow() status = %d, want 204", code)
+	}
+	if got := timeline(); len(got) != 0 {
+		t.Errorf("Timeline after unfollowing = %v, want empty", got)
+	}
+}
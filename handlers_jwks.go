@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// keyRotationInterval is how often the key directory is checked for new keys and for rotation.
+const keyRotationInterval = time.Minute
+
+// jwksMaxAge is how long verifiers may cache the JWKS.
+const jwksMaxAge = 5 * time.Minute
+
+// keyPublishDelay is how long a new key is in the JWKS before it signs: verifiers may have cached
+// the JWKS for jwksMaxAge, and the other servers may find the key in the directory a rotation check late.
+const keyPublishDelay = jwksMaxAge + keyRotationInterval
+
+func (cfg *apiConfig) handlerJWKS(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	responseJSON(resp, 200, cfg.jwtKeys.JWKS())
+}
+
+// rotateJWTKeys keeps the key set in sync with the key directory until the context is done.
+func (cfg *apiConfig) rotateJWTKeys(ctx context.Context) {
+	ticker := time.NewTicker(keyRotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := cfg.jwtKeys.Rotate(now)
+			if err != nil {
+				log.Printf("Error rotating JWT keys: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(resp http.ResponseWriter, req *http.Request) {
+	chirpID, userID, ok := cfg.likeTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.CreateChirpLike(req.Context(), database.CreateChirpLikeParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("Error liking chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(resp http.ResponseWriter, req *http.Request) {
+	chirpID, userID, ok := cfg.likeTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.DeleteChirpLike(req.Context(), database.DeleteChirpLikeParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("Error unliking chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// likeTarget resolves the {chirpID} path value and the current user, writing 400 for an invalid ID
+// and 404 for a chirp that doesn't exist. The user is checked by the middleware already.
+func (cfg *apiConfig) likeTarget(resp http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID := authUserID(req)
+
+	_, err = cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: chirpUUID})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return chirpUUID, userID, true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// getChirp gets the chirp through the handler, as the user when there is one.
+func getChirp(t *testing.T, cfg *apiConfig, viewer database.User, chirpID uuid.UUID) Chirp {
+	t.Helper()
+	handler := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, cfg.handlerGetChirp)
+	code, rec := callAsUser(t, cfg, handler, viewer, "chirpID", chirpID.String(), "")
+	if code != 200 {
+		t.Fatalf("handlerGetChirp() status = %d, want 200", code)
+	}
+	chirp := Chirp{}
+	json.Unmarshal(rec.Body.Bytes(), &chirp)
+	return chirp
+}
+
+func TestHandlerLikeChirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	like := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerLikeChirp)
+	unlike := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerUnlikeChirp)
+	author := store.addUser(auth.RoleUser)
+	fan := store.addUser(auth.RoleUser)
+	chirp := store.addChirp(author.ID)
+
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		chirpID       string
+		wantCode      int
+		wantErr       string
+		wantLikes     int64
+		wantLikedByMe bool
+	}{
+		{
+			name:          "Like",
+			handler:       like,
+			chirpID:       chirp.ID.String(),
+			wantCode:      204,
+			wantLikes:     1,
+			wantLikedByMe: true,
+		},
+		{
+			name:          "Like again",
+			handler:       like,
+			chirpID:       chirp.ID.String(),
+			wantCode:      204,
+			wantLikes:     1,
+			wantLikedByMe: true,
+		},
+		{
+			name:          "Unknown chirp",
+			handler:       like,
+			chirpID:       uuid.New().String(),
+			wantCode:      404,
+			wantErr:       errCodeChirpNotFound,
+			wantLikes:     1,
+			wantLikedByMe: true,
+		},
+		{
+			name:          "Invalid ID",
+			handler:       like,
+			chirpID:       "chirp",
+			wantCode:      400,
+			wantErr:       errCodeInvalidID,
+			wantLikes:     1,
+			wantLikedByMe: true,
+		},
+		{
+			name:      "Unlike",
+			handler:   unlike,
+			chirpID:   chirp.ID.String(),
+			wantCode:  204,
+			wantLikes: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, test.handler, fan, "chirpID", test.chirpID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("Status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			got := getChirp(t, cfg, fan, chirp.ID)
+			if got.LikeCount != test.wantLikes || got.LikedByMe != test.wantLikedByMe {
+				t.Errorf("like_count = %d, liked_by_me = %v, want %d %v", got.LikeCount, got.LikedByMe, test.wantLikes, test.wantLikedByMe)
+			}
+			if byAuthor := getChirp(t, cfg, author, chirp.ID); byAuthor.LikedByMe {
+				t.Errorf("liked_by_me of the author = true, want false")
+			}
+		})
+	}
+}
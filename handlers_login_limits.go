@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/lockout"
+	"github.com/google/uuid"
+)
+
+// The delays between failed logins are the same for accounts and IP addresses;
+// an IP address can be shared by many users, so it gets more attempts before the lockout.
+const (
+	loginFreeAttempts = 3
+	loginBaseDelay    = time.Second
+	loginMaxDelay     = time.Minute
+)
+
+// dbLoginAttempts keeps the failed logins in Postgres, so that all servers share them.
+type dbLoginAttempts struct {
+	db *database.Queries
+}
+
+func (s dbLoginAttempts) Get(ctx context.Context, key string) (lockout.Record, error) {
+	attempt, err := s.db.GetLoginAttempt(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return lockout.Record{}, nil
+	}
+	if err != nil {
+		return lockout.Record{}, err
+	}
+	return lockout.Record{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}, nil
+}
+
+func (s dbLoginAttempts) Swap(ctx context.Context, key string, old, new lockout.Record) (bool, error) {
+	var rows int64
+	var err error
+	if old == (lockout.Record{}) {
+		rows, err = s.db.InsertLoginAttempt(ctx, database.InsertLoginAttemptParams{
+			Key:           key,
+			Failures:      int32(new.Failures),
+			LastFailureAt: new.LastFailure.UTC(),
+		})
+	} else {
+		rows, err = s.db.SwapLoginAttempt(ctx, database.SwapLoginAttemptParams{
+			Failures:         int32(new.Failures),
+			LastFailureAt:    new.LastFailure.UTC(),
+			Key:              key,
+			OldFailures:      int32(old.Failures),
+			OldLastFailureAt: old.LastFailure.UTC(),
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (s dbLoginAttempts) Delete(ctx context.Context, key string) error {
+	return s.db.DeleteLoginAttempt(ctx, key)
+}
+
+func (s dbLoginAttempts) Prune(ctx context.Context, before time.Time) error {
+	return s.db.PruneLoginAttempts(ctx, before.UTC())
+}
+
+// loadLoginLimiters keeps the failed logins in memory, or in Postgres when LOGIN_ATTEMPTS_STORE is "postgres".
+func loadLoginLimiters(dbQueries *database.Queries, storeName string, accountAttempts, ipAttempts int, lockoutDuration time.Duration) (*lockout.Limiter, *lockout.Limiter, error) {
+	var store lockout.Store
+	switch storeName {
+	case "", "memory":
+		store = lockout.NewMemoryStore()
+	case "postgres":
+		store = dbLoginAttempts{db: dbQueries}
+	default:
+		return nil, nil, fmt.Errorf("LOGIN_ATTEMPTS_STORE must be memory or postgres, not %q", storeName)
+	}
+	accounts, ips := newLoginLimiters(store, accountAttempts, ipAttempts, lockoutDuration)
+	return accounts, ips, nil
+}
+
+// newLoginLimiters returns the limiters of the accounts and the IP addresses, which share the store.
+func newLoginLimiters(store lockout.Store, accountAttempts, ipAttempts int, lockoutDuration time.Duration) (*lockout.Limiter, *lockout.Limiter) {
+	policy := lockout.Policy{
+		FreeAttempts:    loginFreeAttempts,
+		BaseDelay:       loginBaseDelay,
+		MaxDelay:        loginMaxDelay,
+		LockoutAfter:    accountAttempts,
+		LockoutDuration: lockoutDuration,
+	}
+	accounts := lockout.NewLimiter(store, policy)
+	policy.LockoutAfter = ipAttempts
+	ips := lockout.NewLimiter(store, policy)
+	return accounts, ips
+}
+
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// reserveLoginAttempt counts the login as failed for the account and the IP address before the password
+// or code is checked, so that parallel guesses cannot pass together before any failure is counted.
+// The account counts also when there is no such account, so that the answers do not tell which accounts exist.
+// It writes a 429 response when the account or the IP address has to wait before the next login.
+// Such attempts are not checked, so they count for neither: the limiter that has to wait does not count
+// them, and the attempt reserved with the other one is released.
+func (cfg *apiConfig) reserveLoginAttempt(resp http.ResponseWriter, req *http.Request, email string) bool {
+	accountWait, accountLocked, err := cfg.loginAccounts.Attempt(req.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error counting login attempt: %s", err)
+		responseInternalError(resp, req)
+		return false
+	}
+	ipWait, ipLocked, err := cfg.loginIPs.Attempt(req.Context(), loginIPKey(clientIP(req)))
+	if err != nil {
+		log.Printf("Error counting login attempt: %s", err)
+		responseInternalError(resp, req)
+		return false
+	}
+	if accountWait <= 0 && ipWait > 0 {
+		err = cfg.loginAccounts.Release(req.Context(), loginAccountKey(email))
+		if err != nil {
+			log.Printf("Error releasing login attempt: %s", err)
+		}
+	}
+	if accountWait > 0 && ipWait <= 0 {
+		err = cfg.loginIPs.Release(req.Context(), loginIPKey(clientIP(req)))
+		if err != nil {
+			log.Printf("Error releasing login attempt: %s", err)
+		}
+	}
+
+	wait := max(accountWait, ipWait)
+	if wait <= 0 {
+		return true
+	}
+
+	type details struct {
+		RetryAfter int  `json:"retry_after"`
+		Locked     bool `json:"locked"`
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	locked := accountLocked || ipLocked
+	message := fmt.Sprintf("Too many failed logins, try again in %d seconds", seconds)
+	if locked {
+		message = fmt.Sprintf("Too many failed logins, logging in is locked for %d seconds", seconds)
+	}
+	resp.Header().Set("Retry-After", strconv.Itoa(seconds))
+	responseErrorDetails(resp, req, 429, errCodeTooManyLoginAttempts, message, details{
+		RetryAfter: seconds,
+		Locked:     locked,
+	})
+	return false
+}
+
+// releaseLoginAttempt takes back the attempt reserved by reserveLoginAttempt when the password or code was right.
+func (cfg *apiConfig) releaseLoginAttempt(req *http.Request, email string) {
+	err := cfg.loginAccounts.Release(req.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error releasing login attempt: %s", err)
+	}
+	err = cfg.loginIPs.Release(req.Context(), loginIPKey(clientIP(req)))
+	if err != nil {
+		log.Printf("Error releasing login attempt: %s", err)
+	}
+}
+
+// succeedLogin forgets the failures of the account. Those of the IP address stay,
+// so logging in to an own account does not make guessing other passwords faster.
+func (cfg *apiConfig) succeedLogin(req *http.Request, email string) {
+	err := cfg.loginAccounts.Reset(req.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error resetting failed logins: %s", err)
+	}
+}
+
+func (cfg *apiConfig) handlerUnlockUser(resp http.ResponseWriter, req *http.Request) {
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("Error parsing UserID to UUID: %s", err)
+		responseInvalidID(resp, req, "userID")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	err = cfg.loginAccounts.Reset(req.Context(), loginAccountKey(user.Email.String))
+	if err != nil {
+		log.Printf("Error unlocking user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// pruneLoginAttempts deletes the failed logins that are forgotten already, every purgeInterval until the context is done.
+func (cfg *apiConfig) pruneLoginAttempts(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, limiter := range []*lockout.Limiter{cfg.loginAccounts, cfg.loginIPs} {
+			err := limiter.Prune(ctx)
+			if err != nil {
+				log.Printf("Error pruning login attempts: %s", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
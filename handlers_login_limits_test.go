@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/lockout"
+)
+
+func newLoginLimitsTestConfig(t *testing.T) (*apiConfig, *lockout.MemoryStore) {
+	t.Helper()
+	store := lockout.NewMemoryStore()
+	accounts, ips := newLoginLimiters(store, 5, 8, 15*time.Minute)
+	return &apiConfig{loginAccounts: accounts, loginIPs: ips}, store
+}
+
+func loginRequest(ip string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/login", nil)
+	req.RemoteAddr = ip + ":51234"
+	return req
+}
+
+func reserveLogin(cfg *apiConfig, email, ip string) (bool, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	return cfg.reserveLoginAttempt(rec, loginRequest(ip), email), rec
+}
+
+// failLogins saves n failures of the account from the IP address, without waiting out the delays between them.
+func failLogins(t *testing.T, store *lockout.MemoryStore, email, ip string, n int) {
+	t.Helper()
+	for _, key := range []string{loginAccountKey(email), loginIPKey(ip)} {
+		record, _ := store.Get(context.Background(), key)
+		swapped, err := store.Swap(context.Background(), key, record, lockout.Record{Failures: record.Failures + n, LastFailure: time.Now()})
+		if err != nil || !swapped {
+			t.Fatalf("Swap() = %v, %v, want the failures saved", swapped, err)
+		}
+	}
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   map[string]int // email@ip -> failures
+		email      string
+		ip         string
+		wantOK     bool
+		wantLocked bool
+	}{
+		{
+			name:     "Free attempts",
+			failures: map[string]int{"user@example.com@203.0.113.7": loginFreeAttempts},
+			email:    "user@example.com",
+			ip:       "203.0.113.7",
+			wantOK:   true,
+		},
+		{
+			name:     "Backoff after the free attempts",
+			failures: map[string]int{"user@example.com@203.0.113.7": loginFreeAttempts + 1},
+			email:    "user@example.com",
+			ip:       "203.0.113.7",
+			wantOK:   false,
+		},
+		{
+			name:       "Account is locked from every IP address",
+			failures:   map[string]int{"user@example.com@203.0.113.7": 5},
+			email:      "User@Example.com",
+			ip:         "198.51.100.1",
+			wantOK:     false,
+			wantLocked: true,
+		},
+		{
+			name:     "Other accounts are not affected",
+			failures: map[string]int{"user@example.com@203.0.113.7": 5},
+			email:    "other@example.com",
+			ip:       "198.51.100.1",
+			wantOK:   true,
+		},
+		{
+			name: "IP address is locked for every account",
+			failures: map[string]int{
+				"a@example.com@203.0.113.7": 3,
+				"b@example.com@203.0.113.7": 3,
+				"c@example.com@203.0.113.7": 2,
+			},
+			email:      "d@example.com",
+			ip:         "203.0.113.7",
+			wantOK:     false,
+			wantLocked: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store := newLoginLimitsTestConfig(t)
+			for key, n := range test.failures {
+				i := strings.LastIndex(key, "@")
+				failLogins(t, store, key[:i], key[i+1:], n)
+			}
+
+			ok, rec := reserveLogin(cfg, test.email, test.ip)
+			if ok != test.wantOK {
+				t.Fatalf("reserveLoginAttempt() = %v, want %v", ok, test.wantOK)
+			}
+			if ok {
+				return
+			}
+
+			if rec.Code != 429 || !strings.Contains(rec.Body.String(), errCodeTooManyLoginAttempts) {
+				t.Errorf("Response = %d %s, want 429 %q", rec.Code, rec.Body.String(), errCodeTooManyLoginAttempts)
+			}
+			retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+			if err != nil || retryAfter < 1 {
+				t.Errorf("Retry-After = %q, want seconds", rec.Header().Get("Retry-After"))
+			}
+			if strings.Contains(rec.Body.String(), `"locked":true`) != test.wantLocked {
+				t.Errorf("Response = %s, want locked %v", rec.Body.String(), test.wantLocked)
+			}
+		})
+	}
+}
+
+func TestReserveLoginAttemptRefusedDoesNotCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures map[string]int // email@ip -> failures
+		email    string
+		ip       string
+		otherKey string
+	}{
+		{
+			name:     "Account has to wait",
+			failures: map[string]int{"user@example.com@203.0.113.7": 5},
+			email:    "user@example.com",
+			ip:       "198.51.100.1",
+			otherKey: loginIPKey("198.51.100.1"),
+		},
+		{
+			name: "IP address has to wait",
+			failures: map[string]int{
+				"a@example.com@203.0.113.7": 4,
+				"b@example.com@203.0.113.7": 4,
+			},
+			email:    "c@example.com",
+			ip:       "203.0.113.7",
+			otherKey: loginAccountKey("c@example.com"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store := newLoginLimitsTestConfig(t)
+			for key, n := range test.failures {
+				i := strings.LastIndex(key, "@")
+				failLogins(t, store, key[:i], key[i+1:], n)
+			}
+
+			for i := 0; i < 10; i++ {
+				if ok, _ := reserveLogin(cfg, test.email, test.ip); ok {
+					t.Fatalf("reserveLoginAttempt() = true, want a refused login")
+				}
+			}
+			record, _ := store.Get(context.Background(), test.otherKey)
+			if record.Failures != 0 {
+				t.Errorf("Failures of %s = %d after refused logins, want 0", test.otherKey, record.Failures)
+			}
+		})
+	}
+}
+
+func TestSucceedLoginKeepsIPFailures(t *testing.T) {
+	cfg, store := newLoginLimitsTestConfig(t)
+	failLogins(t, store, "user@example.com", "203.0.113.7", 4)
+
+	cfg.succeedLogin(loginRequest("203.0.113.7"), "user@example.com")
+
+	ok, _ := reserveLogin(cfg, "user@example.com", "198.51.100.1")
+	if !ok {
+		t.Errorf("Account still has to wait after a successful login")
+	}
+	ok, _ = reserveLogin(cfg, "other@example.com", "203.0.113.7")
+	if ok {
+		t.Errorf("IP address failures were forgotten after a successful login")
+	}
+}
+
+func TestReserveLoginAttemptInParallel(t *testing.T) {
+	cfg, _ := newLoginLimitsTestConfig(t)
+
+	var wg sync.WaitGroup
+	results := make(chan bool, 50)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _ := reserveLogin(cfg, "user@example.com", "203.0.113.7")
+			results <- ok
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	reserved := 0
+	for ok := range results {
+		if ok {
+			reserved++
+		}
+	}
+	if want := loginFreeAttempts + 1; reserved != want {
+		t.Errorf("Parallel logins checked = %d, want %d", reserved, want)
+	}
+}
+
+func TestReleaseLoginAttempt(t *testing.T) {
+	cfg, _ := newLoginLimitsTestConfig(t)
+
+	for i := 0; i < 10; i++ {
+		ok, _ := reserveLogin(cfg, "user@example.com", "203.0.113.7")
+		if !ok {
+			t.Fatalf("Login %d has to wait, although every login before was right", i+1)
+		}
+		cfg.releaseLoginAttempt(loginRequest("203.0.113.7"), "user@example.com")
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 1000
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "misinformation", "other"}
+
+// Statuses of chirp reports. Reports are open until a moderator acts on them.
+const (
+	reportStatusOpen      = "open"
+	reportStatusActioned  = "actioned"
+	reportStatusDismissed = "dismissed"
+)
+
+// Actions moderators take on reports, recorded in moderation_actions.
+const (
+	moderationHide    = "hide"
+	moderationDismiss = "dismiss"
+	moderationSuspend = "suspend"
+)
+
+type ChirpReport struct {
+	ID         uuid.UUID  `json:"id"`
+	ChirpID    uuid.UUID  `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	AssignedTo *uuid.UUID `json:"assigned_to"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+func chirpReportFromDB(report database.ChirpReport) ChirpReport {
+	respBody := ChirpReport{
+		ID:         report.ID,
+		ChirpID:    report.ChirpID,
+		ReporterID: report.ReporterID,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		CreatedAt:  report.CreatedAt,
+	}
+	if report.AssignedTo.Valid {
+		respBody.AssignedTo = &report.AssignedTo.UUID
+	}
+	if report.ResolvedAt.Valid {
+		respBody.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return respBody
+}
+
+type QueuedReport struct {
+	ChirpReport
+	AuthorID    uuid.UUID `json:"author_id"`
+	ChirpBody   string    `json:"chirp_body"`
+	ChirpHidden bool      `json:"chirp_hidden"`
+	OpenReports int64     `json:"open_reports"`
+}
+
+type ReportQueuePage struct {
+	Reports    []QueuedReport `json:"reports"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID  `json:"id"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	ReportID    *uuid.UUID `json:"report_id"`
+	ChirpID     *uuid.UUID `json:"chirp_id"`
+	UserID      *uuid.UUID `json:"user_id"`
+	Note        string     `json:"note"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	respBody := ModerationAction{
+		ID:        action.ID,
+		Action:    action.Action,
+		Note:      action.Note,
+		CreatedAt: action.CreatedAt,
+	}
+	if action.ModeratorID.Valid {
+		respBody.ModeratorID = &action.ModeratorID.UUID
+	}
+	if action.ReportID.Valid {
+		respBody.ReportID = &action.ReportID.UUID
+	}
+	if action.ChirpID.Valid {
+		respBody.ChirpID = &action.ChirpID.UUID
+	}
+	if action.UserID.Valid {
+		respBody.UserID = &action.UserID.UUID
+	}
+	return respBody
+}
+
+func (cfg *apiConfig) handlerReportChirp(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	if !slices.Contains(reportReasons, params.Reason) {
+		responseError(resp, req, 400, errCodeInvalidParameter, "reason must be spam, harassment, hate, violence, misinformation or other")
+		return
+	}
+	if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+		responseError(resp, req, 400, errCodeInvalidParameter, "details can have up to 1000 characters")
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: chirpUUID})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	report, err := cfg.dbQueries.CreateChirpReport(req.Context(), database.CreateChirpReportParams{
+		ChirpID:    chirpUUID,
+		ReporterID: authUserID(req),
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 409, errCodeAlreadyReported, "You have reported this chirp already")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating report: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 201, chirpReportFromDB(report))
+}
+
+// handlerGetReportQueue lists the reports for moderators, oldest first. assigned_to is
+// a moderator's ID, "me" or "none" for the reports nobody has picked up yet.
+func (cfg *apiConfig) handlerGetReportQueue(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	status := query.Get("status")
+	if status == "" {
+		status = reportStatusOpen
+	}
+	if status != reportStatusOpen && status != reportStatusActioned && status != reportStatusDismissed {
+		responseError(resp, req, 400, errCodeInvalidParameter, "status must be open, actioned or dismissed")
+		return
+	}
+
+	var reason sql.NullString
+	if query.Get("reason") != "" {
+		if !slices.Contains(reportReasons, query.Get("reason")) {
+			responseError(resp, req, 400, errCodeInvalidParameter, "reason must be spam, harassment, hate, violence, misinformation or other")
+			return
+		}
+		reason = sql.NullString{String: query.Get("reason"), Valid: true}
+	}
+
+	var assignedTo uuid.NullUUID
+	unassigned := false
+	switch query.Get("assigned_to") {
+	case "":
+	case "none":
+		unassigned = true
+	case "me":
+		assignedTo = uuid.NullUUID{UUID: authUserID(req), Valid: true}
+	default:
+		parsed, err := uuid.Parse(query.Get("assigned_to"))
+		if err != nil {
+			responseError(resp, req, 400, errCodeInvalidParameter, "assigned_to must be a user ID, me or none")
+			return
+		}
+		assignedTo = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	// one extra row tells us whether there is a next page
+	rows, err := cfg.dbQueries.GetReportQueue(req.Context(), database.GetReportQueueParams{
+		Status:          status,
+		Reason:          reason,
+		AssignedTo:      assignedTo,
+		Unassigned:      unassigned,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting report queue: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	page := ReportQueuePage{Reports: []QueuedReport{}}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1].ChirpReport
+		page.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+	for _, row := range rows {
+		page.Reports = append(page.Reports, QueuedReport{
+			ChirpReport: chirpReportFromDB(row.ChirpReport),
+			AuthorID:    row.AuthorID,
+			ChirpBody:   row.ChirpBody,
+			ChirpHidden: row.ChirpHiddenAt.Valid,
+			OpenReports: row.OpenReports,
+		})
+	}
+
+	responseJSON(resp, 200, page)
+}
+
+// handlerAssignReport assigns a report to a moderator, or unassigns it when moderator_id is null.
+func (cfg *apiConfig) handlerAssignReport(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		ModeratorID *uuid.UUID `json:"moderator_id"`
+	}
+
+	reportUUID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		log.Printf("Error parsing ReportID to UUID: %s", err)
+		responseInvalidID(resp, req, "reportID")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	var assignee uuid.NullUUID
+	if params.ModeratorID != nil {
+		moderator, err := cfg.dbQueries.GetUser(req.Context(), *params.ModeratorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting user: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+		if !auth.HasRole(moderator.Role, auth.RoleModerator) {
+			responseError(resp, req, 400, errCodeInvalidParameter, "Reports can only be assigned to moderators")
+			return
+		}
+		assignee = uuid.NullUUID{UUID: moderator.ID, Valid: true}
+	}
+
+	report, err := cfg.dbQueries.AssignChirpReport(req.Context(), database.AssignChirpReportParams{
+		ID:         reportUUID,
+		AssignedTo: assignee,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeReportNotFound, "Report not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error assigning report: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 200, chirpReportFromDB(report))
+}
+
+// handlerModerateReport resolves an open report. hide takes the chirp down and resolves all
+// its open reports, dismiss only resolves this report, and suspend locks the author out
+// until suspended_until. Every action is recorded in moderation_actions, in the same statement
+// that resolves the report and applies the action, so none of them is done without the others.
+func (cfg *apiConfig) handlerModerateReport(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Action         string     `json:"action"`
+		Note           string     `json:"note"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	reportUUID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		log.Printf("Error parsing ReportID to UUID: %s", err)
+		responseInvalidID(resp, req, "reportID")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	switch params.Action {
+	case moderationHide, moderationDismiss:
+	case moderationSuspend:
+		if params.SuspendedUntil == nil || !params.SuspendedUntil.After(time.Now()) {
+			responseError(resp, req, 400, errCodeInvalidParameter, "suspended_until must be in the future")
+			return
+		}
+	default:
+		responseError(resp, req, 400, errCodeInvalidParameter, "action must be hide, dismiss or suspend")
+		return
+	}
+	if utf8.RuneCountInString(params.Note) > maxReportDetailsLength {
+		responseError(resp, req, 400, errCodeInvalidParameter, "note can have up to 1000 characters")
+		return
+	}
+
+	report, err := cfg.dbQueries.GetChirpReport(req.Context(), reportUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeReportNotFound, "Report not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting report: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if report.ChirpReport.Status != reportStatusOpen {
+		responseError(resp, req, 409, errCodeReportAlreadyResolved, "The report has been resolved already")
+		return
+	}
+
+	if params.Action == moderationSuspend {
+		author, err := cfg.dbQueries.GetUser(req.Context(), report.AuthorID)
+		if err != nil {
+			log.Printf("Error getting user: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+		if auth.HasRole(author.Role, auth.RoleModerator) {
+			responseError(resp, req, 403, errCodeForbidden, "Moderators and admins cannot be suspended")
+			return
+		}
+	}
+
+	status := reportStatusActioned
+	if params.Action == moderationDismiss {
+		status = reportStatusDismissed
+	}
+	var suspendedUntil sql.NullTime
+	if params.Action == moderationSuspend {
+		suspendedUntil = sql.NullTime{Time: params.SuspendedUntil.UTC(), Valid: true}
+	}
+	action, err := cfg.dbQueries.ModerateChirpReport(req.Context(), database.ModerateChirpReportParams{
+		ReportID:       reportUUID,
+		Status:         status,
+		ModeratorID:    authUserID(req),
+		Action:         params.Action,
+		AuthorID:       report.AuthorID,
+		SuspendedUntil: suspendedUntil,
+		Note:           params.Note,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// another moderator resolved it in the meantime
+		responseError(resp, req, 409, errCodeReportAlreadyResolved, "The report has been resolved already")
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying moderation action %s: %s", params.Action, err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 201, moderationActionFromDB(action))
+}
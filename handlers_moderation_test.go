@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerReportChirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerReportChirp)
+	reporter := store.addUser(auth.RoleUser)
+	chirp := store.addChirp(store.addUser(auth.RoleUser).ID)
+	hidden := store.addChirp(chirp.UserID)
+	hidden.HiddenAt = sql.NullTime{Time: hidden.CreatedAt, Valid: true}
+	store.chirps[hidden.ID] = hidden
+
+	tests := []struct {
+		name     string
+		chirpID  uuid.UUID
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Unknown reason",
+			chirpID:  chirp.ID,
+			body:     `{"reason": "boring"}`,
+			wantCode: 400,
+			wantErr:  errCodeInvalidParameter,
+		},
+		{
+			name:     "Unknown chirp",
+			chirpID:  uuid.New(),
+			body:     `{"reason": "spam"}`,
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Hidden chirp",
+			chirpID:  hidden.ID,
+			body:     `{"reason": "spam"}`,
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Report",
+			chirpID:  chirp.ID,
+			body:     `{"reason": "spam", "details": "Same link every minute"}`,
+			wantCode: 201,
+		},
+		{
+			name:     "Reported again",
+			chirpID:  chirp.ID,
+			body:     `{"reason": "hate"}`,
+			wantCode: 409,
+			wantErr:  errCodeAlreadyReported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, reporter, "chirpID", test.chirpID.String(), test.body)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerReportChirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+
+	if len(store.reports) != 1 || store.reports[0].Reason != "spam" {
+		t.Errorf("Store has reports %+v, want the spam report", store.reports)
+	}
+}
+
+func TestHandlerModerateReport(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorRole    string
+		body          string
+		wantCode      int
+		wantErr       string
+		wantStatus    string
+		wantOther     string
+		wantHidden    bool
+		wantSuspended bool
+	}{
+		{
+			name:       "Hide",
+			authorRole: auth.RoleUser,
+			body:       `{"action": "hide", "note": "Spam"}`,
+			wantCode:   201,
+			wantStatus: reportStatusActioned,
+			wantOther:  reportStatusActioned,
+			wantHidden: true,
+		},
+		{
+			name:       "Dismiss",
+			authorRole: auth.RoleUser,
+			body:       `{"action": "dismiss"}`,
+			wantCode:   201,
+			wantStatus: reportStatusDismissed,
+			wantOther:  reportStatusOpen,
+		},
+		{
+			name:          "Suspend",
+			authorRole:    auth.RoleUser,
+			body:          `{"action": "suspend", "suspended_until": "` + time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`,
+			wantCode:      201,
+			wantStatus:    reportStatusActioned,
+			wantOther:     reportStatusOpen,
+			wantSuspended: true,
+		},
+		{
+			name:       "Suspend without an end",
+			authorRole: auth.RoleUser,
+			body:       `{"action": "suspend"}`,
+			wantCode:   400,
+			wantErr:    errCodeInvalidParameter,
+			wantStatus: reportStatusOpen,
+			wantOther:  reportStatusOpen,
+		},
+		{
+			name:       "Suspend a moderator",
+			authorRole: auth.RoleModerator,
+			body:       `{"action": "suspend", "suspended_until": "` + time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`,
+			wantCode:   403,
+			wantErr:    errCodeForbidden,
+			wantStatus: reportStatusOpen,
+			wantOther:  reportStatusOpen,
+		},
+		{
+			name:       "Unknown action",
+			authorRole: auth.RoleUser,
+			body:       `{"action": "delete"}`,
+			wantCode:   400,
+			wantErr:    errCodeInvalidParameter,
+			wantStatus: reportStatusOpen,
+			wantOther:  reportStatusOpen,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store := newTestConfig(t)
+			handler := cfg.middlewareRole(auth.RoleModerator, cfg.handlerModerateReport)
+			moderator := store.addUser(auth.RoleModerator)
+			author := store.addUser(test.authorRole)
+			chirp := store.addChirp(author.ID)
+			report, _ := store.CreateChirpReport(context.Background(), database.CreateChirpReportParams{
+				ChirpID: chirp.ID, ReporterID: uuid.New(), Reason: "spam",
+			})
+			other, _ := store.CreateChirpReport(context.Background(), database.CreateChirpReportParams{
+				ChirpID: chirp.ID, ReporterID: uuid.New(), Reason: "other",
+			})
+
+			code, rec := callAsUser(t, cfg, handler, moderator, "reportID", report.ID.String(), test.body)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerModerateReport() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if status := store.report(report.ID).Status; status != test.wantStatus {
+				t.Errorf("Report status = %q, want %q", status, test.wantStatus)
+			}
+			if status := store.report(other.ID).Status; status != test.wantOther {
+				t.Errorf("Other report of the chirp status = %q, want %q", status, test.wantOther)
+			}
+			if hidden := store.chirps[chirp.ID].HiddenAt.Valid; hidden != test.wantHidden {
+				t.Errorf("Chirp hidden = %v, want %v", hidden, test.wantHidden)
+			}
+			if suspended := store.users[author.ID].SuspendedUntil.Valid; suspended != test.wantSuspended {
+				t.Errorf("Author suspended = %v, want %v", suspended, test.wantSuspended)
+			}
+			if revoked := len(store.revoked) > 0; revoked != test.wantSuspended {
+				t.Errorf("Author's sessions revoked = %v, want %v", revoked, test.wantSuspended)
+			}
+
+			wantActions := 0
+			if test.wantCode == 201 {
+				wantActions = 1
+			}
+			if len(store.actions) != wantActions {
+				t.Fatalf("Store has %d moderation actions, want %d", len(store.actions), wantActions)
+			}
+			if wantActions == 1 && (store.actions[0].ModeratorID.UUID != moderator.ID || store.actions[0].UserID.UUID != author.ID) {
+				t.Errorf("Moderation action = %+v, want one by %v about %v", store.actions[0], moderator.ID, author.ID)
+			}
+		})
+	}
+}
+
+func TestHandlerModerateReportOnlyOnce(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareRole(auth.RoleModerator, cfg.handlerModerateReport)
+	moderator := store.addUser(auth.RoleModerator)
+	chirp := store.addChirp(store.addUser(auth.RoleUser).ID)
+	report, _ := store.CreateChirpReport(context.Background(), database.CreateChirpReportParams{
+		ChirpID: chirp.ID, ReporterID: uuid.New(), Reason: "spam",
+	})
+
+	code, _ := callAsUser(t, cfg, handler, moderator, "reportID", report.ID.String(), `{"action": "dismiss"}`)
+	if code != 201 {
+		t.Fatalf("First action: status = %d, want 201", code)
+	}
+	code, rec := callAsUser(t, cfg, handler, moderator, "reportID", report.ID.String(), `{"action": "hide"}`)
+	if code != 409 || errorCode(rec) != errCodeReportAlreadyResolved {
+		t.Errorf("Second action: status = %d, code = %q, want 409 %q", code, errorCode(rec), errCodeReportAlreadyResolved)
+	}
+	if store.chirps[chirp.ID].HiddenAt.Valid {
+		t.Errorf("Chirp is hidden by the action on a resolved report")
+	}
+}
+
+func TestHandlerGetReportQueue(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareRole(auth.RoleModerator, cfg.handlerGetReportQueue)
+	moderator := store.addUser(auth.RoleModerator)
+	chirp := store.addChirp(store.addUser(auth.RoleUser).ID)
+	spam, _ := store.CreateChirpReport(context.Background(), database.CreateChirpReportParams{
+		ChirpID: chirp.ID, ReporterID: uuid.New(), Reason: "spam",
+	})
+	hate, _ := store.CreateChirpReport(context.Background(), database.CreateChirpReportParams{
+		ChirpID: chirp.ID, ReporterID: uuid.New(), Reason: "hate",
+	})
+	store.AssignChirpReport(context.Background(), database.AssignChirpReportParams{
+		ID:         hate.ID,
+		AssignedTo: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+	})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []uuid.UUID
+	}{
+		{
+			name:     "Open reports",
+			query:    "",
+			wantCode: 200,
+			want:     []uuid.UUID{spam.ID, hate.ID},
+		},
+		{
+			name:     "By reason",
+			query:    "?reason=spam",
+			wantCode: 200,
+			want:     []uuid.UUID{spam.ID},
+		},
+		{
+			name:     "Assigned to me",
+			query:    "?assigned_to=me",
+			wantCode: 200,
+			want:     []uuid.UUID{hate.ID},
+		},
+		{
+			name:     "Unassigned",
+			query:    "?assigned_to=none",
+			wantCode: 200,
+			want:     []uuid.UUID{spam.ID},
+		},
+		{
+			name:     "Dismissed reports",
+			query:    "?status=dismissed",
+			wantCode: 200,
+			want:     []uuid.UUID{},
+		},
+		{
+			name:     "Unknown status",
+			query:    "?status=closed",
+			wantCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, _ := auth.MakeJWT(moderator.ID, moderator.Role, cfg.jwtKeys, time.Hour)
+			req := httptest.NewRequest("GET", "/admin/reports"+test.query, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != test.wantCode {
+				t.Fatalf("handlerGetReportQueue() status = %d, want %d", rec.Code, test.wantCode)
+			}
+			if test.wantCode != 200 {
+				return
+			}
+
+			page := ReportQueuePage{}
+			json.Unmarshal(rec.Body.Bytes(), &page)
+			got := []uuid.UUID{}
+			for _, report := range page.Reports {
+				got = append(got, report.ID)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("handlerGetReportQueue() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("handlerGetReportQueue() = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/oidc"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcStateExpiration = 10 * time.Minute
+	oidcStateCookie     = "chirpy_oidc_state"
+	// unsetPassword is the hashed_password of users without a password, like those from identity providers.
+	unsetPassword = "unset"
+)
+
+type AuthorizationURL struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// loadOIDCProviders configures the providers listed in OIDC_PROVIDERS (e.g. "google,gitlab")
+// from OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET. The providers
+// redirect back to OIDC_REDIRECT_BASE_URL + /api/oidc/<name>/callback.
+func loadOIDCProviders() (map[string]*oidc.Provider, error) {
+	providers := map[string]*oidc.Provider{}
+	names := strings.TrimSpace(os.Getenv("OIDC_PROVIDERS"))
+	if names == "" {
+		return providers, nil
+	}
+
+	baseURL := strings.TrimSuffix(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, errors.New("OIDC_REDIRECT_BASE_URL is required for OIDC_PROVIDERS")
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider, err := oidc.NewProvider(oidc.Config{
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  baseURL + "/api/oidc/" + name + "/callback",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Provider %s: %w", name, err)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+func (cfg *apiConfig) oidcProvider(resp http.ResponseWriter, req *http.Request) (string, *oidc.Provider, bool) {
+	name := req.PathValue("provider")
+	provider, ok := cfg.oidcProviders[name]
+	if !ok {
+		responseError(resp, req, 404, errCodeProviderNotFound, "Identity provider not found")
+		return "", nil, false
+	}
+	return name, provider, true
+}
+
+// startOIDCLogin remembers a new login at the provider and returns where to send the user, and the state
+// for setOIDCStateCookie. With a user ID the callback links the identity to that user instead of logging in.
+func (cfg *apiConfig) startOIDCLogin(ctx context.Context, name string, provider *oidc.Provider, userID uuid.NullUUID) (string, string, error) {
+	err := cfg.dbQueries.DeleteExpiredOIDCStates(ctx)
+	if err != nil {
+		log.Printf("Error deleting expired OIDC states: %s", err)
+	}
+
+	state, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = cfg.dbQueries.CreateOIDCState(ctx, database.CreateOIDCStateParams{
+		StateHash:    auth.HashRefreshToken(state),
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		ExpiresAt:    time.Now().UTC().Add(oidcStateExpiration),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// setOIDCStateCookie binds the login to the browser that starts it. The callback accepts the state only
+// together with this cookie, so nobody can send another user's browser to finish their own login or link.
+func setOIDCStateCookie(resp http.ResponseWriter, provider *oidc.Provider, state string) {
+	http.SetCookie(resp, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc/",
+		MaxAge:   int(oidcStateExpiration.Seconds()),
+		Secure:   strings.HasPrefix(provider.RedirectURL(), "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (cfg *apiConfig) handlerOIDCLogin(resp http.ResponseWriter, req *http.Request) {
+	name, provider, ok := cfg.oidcProvider(resp, req)
+	if !ok {
+		return
+	}
+
+	authURL, state, err := cfg.startOIDCLogin(req.Context(), name, provider, uuid.NullUUID{})
+	if err != nil {
+		log.Printf("Error starting OIDC login: %s", err)
+		responseError(resp, req, 502, errCodeProviderUnavailable, "Identity provider is not available")
+		return
+	}
+	setOIDCStateCookie(resp, provider, state)
+	http.Redirect(resp, req, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerOIDCLink(resp http.ResponseWriter, req *http.Request) {
+	name, provider, ok := cfg.oidcProvider(resp, req)
+	if !ok {
+		return
+	}
+
+	userID := uuid.NullUUID{UUID: authUserID(req), Valid: true}
+	authURL, state, err := cfg.startOIDCLogin(req.Context(), name, provider, userID)
+	if err != nil {
+		log.Printf("Error starting OIDC link: %s", err)
+		responseError(resp, req, 502, errCodeProviderUnavailable, "Identity provider is not available")
+		return
+	}
+	setOIDCStateCookie(resp, provider, state)
+	responseJSON(resp, 200, AuthorizationURL{AuthorizationURL: authURL})
+}
+
+// handlerOIDCCallback is where the provider sends the user back. Known identities log in to
+// their user; a new identity gets a new user, unless its email belongs to an existing user,
+// who has to log in and link the provider first, so a provider cannot take over accounts.
+func (cfg *apiConfig) handlerOIDCCallback(resp http.ResponseWriter, req *http.Request) {
+	name, provider, ok := cfg.oidcProvider(resp, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	stateToken := query.Get("state")
+	if stateToken == "" {
+		responseError(resp, req, 400, errCodeInvalidOIDCState, "The login state is missing")
+		return
+	}
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(stateToken)) != 1 {
+		responseError(resp, req, 400, errCodeInvalidOIDCState, "The login was started in another browser")
+		return
+	}
+	http.SetCookie(resp, &http.Cookie{Name: oidcStateCookie, Path: "/api/oidc/", MaxAge: -1})
+	state, err := cfg.dbQueries.ClaimOIDCState(req.Context(), auth.HashRefreshToken(stateToken))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error claiming OIDC state: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if err != nil || state.Provider != name || !state.ExpiresAt.After(time.Now().UTC()) {
+		responseError(resp, req, 400, errCodeInvalidOIDCState, "The login state is invalid, expired or already used")
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("OIDC provider %s returned error: %s %s", name, providerErr, query.Get("error_description"))
+		responseError(resp, req, 401, errCodeOIDCLoginFailed, "The identity provider did not log the user in")
+		return
+	}
+
+	identity, err := provider.Login(req.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Error logging in with OIDC provider %s: %s", name, err)
+		responseError(resp, req, 401, errCodeOIDCLoginFailed, "The identity provider did not log the user in")
+		return
+	}
+
+	existing, err := cfg.dbQueries.GetUserIdentity(req.Context(), database.GetUserIdentityParams{
+		Provider: name,
+		Subject:  identity.Subject,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user identity: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	found := err == nil
+
+	if state.UserID.Valid {
+		cfg.linkIdentity(resp, req, name, identity, state.UserID.UUID, existing, found)
+		return
+	}
+
+	var user database.User
+	if found {
+		err = cfg.dbQueries.TouchUserIdentity(req.Context(), database.TouchUserIdentityParams{
+			ID:    existing.ID,
+			Email: sql.NullString{String: identity.Email, Valid: identity.Email != ""},
+		})
+		if err != nil {
+			log.Printf("Error updating user identity: %s", err)
+		}
+		user, err = cfg.dbQueries.GetUser(req.Context(), existing.UserID)
+		if err != nil {
+			log.Printf("Error getting user: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+	} else {
+		user, ok = cfg.createExternalUser(resp, req, name, identity)
+		if !ok {
+			return
+		}
+	}
+
+	userTOTP, err := cfg.dbQueries.GetUserTOTP(req.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting TOTP: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if err == nil && userTOTP.EnabledAt.Valid {
+		cfg.startLoginChallenge(resp, req, user.ID)
+		return
+	}
+
+	cfg.completeLogin(resp, req, user)
+}
+
+// createExternalUser signs up the user of a new identity with the email verified by the provider.
+func (cfg *apiConfig) createExternalUser(resp http.ResponseWriter, req *http.Request, name string, identity oidc.Identity) (database.User, bool) {
+	email, err := parseEmail(identity.Email)
+	if err != nil || !identity.EmailVerified {
+		responseError(resp, req, 401, errCodeOIDCLoginFailed, "The identity provider did not share a verified email")
+		return database.User{}, false
+	}
+
+	_, err = cfg.dbQueries.GetUserByEmail(req.Context(), sql.NullString{String: email, Valid: true})
+	if err == nil {
+		responseError(resp, req, 409, errCodeIdentityNotLinked, "A user with this email already exists, log in and link the identity provider first")
+		return database.User{}, false
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return database.User{}, false
+	}
+
+	user, err := cfg.dbQueries.CreateExternalUser(req.Context(), database.CreateExternalUserParams{
+		Email:         sql.NullString{String: email, Valid: true},
+		Provider:      name,
+		Subject:       identity.Subject,
+		IdentityEmail: sql.NullString{String: identity.Email, Valid: true},
+	})
+	if isUniqueViolation(err) {
+		responseError(resp, req, 409, errCodeIdentityNotLinked, "A user with this email already exists, log in and link the identity provider first")
+		return database.User{}, false
+	}
+	if err != nil {
+		log.Printf("Error creating user: %s", err)
+		responseInternalError(resp, req)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// linkIdentity finishes handlerOIDCLink: the user was logged in when the link started,
+// so the response has no new tokens.
+func (cfg *apiConfig) linkIdentity(resp http.ResponseWriter, req *http.Request, name string, identity oidc.Identity, userID uuid.UUID, existing database.UserIdentity, found bool) {
+	if found && existing.UserID != userID {
+		responseError(resp, req, 409, errCodeIdentityAlreadyLinked, "This identity is linked to another user")
+		return
+	}
+
+	if !found {
+		_, err := cfg.dbQueries.CreateUserIdentity(req.Context(), database.CreateUserIdentityParams{
+			UserID:   userID,
+			Provider: name,
+			Subject:  identity.Subject,
+			Email:    sql.NullString{String: identity.Email, Valid: identity.Email != ""},
+		})
+		if isUniqueViolation(err) {
+			responseError(resp, req, 409, errCodeIdentityAlreadyLinked, "Another identity of this provider is linked to the user")
+			return
+		}
+		if err != nil {
+			log.Printf("Error creating user identity: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, userFromDB(user))
+}
+
+type Identity struct {
+	Provider    string    `json:"provider"`
+	Email       string    `json:"email,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+func (cfg *apiConfig) handlerGetIdentities(resp http.ResponseWriter, req *http.Request) {
+	identities, err := cfg.dbQueries.GetUserIdentities(req.Context(), authUserID(req))
+	if err != nil {
+		log.Printf("Error getting user identities: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := []Identity{}
+	for _, identity := range identities {
+		respBody = append(respBody, Identity{
+			Provider:    identity.Provider,
+			Email:       identity.Email.String,
+			CreatedAt:   identity.CreatedAt,
+			LastLoginAt: identity.LastLoginAt,
+		})
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+// handlerUnlinkIdentity refuses to unlink the only identity of a user without a password,
+// who could not log in any more.
+func (cfg *apiConfig) handlerUnlinkIdentity(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
+	provider := req.PathValue("provider")
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	identities, err := cfg.dbQueries.GetUserIdentities(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user identities: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if user.HashedPassword == unsetPassword && len(identities) == 1 && identities[0].Provider == provider {
+		responseError(resp, req, 409, errCodeLastIdentity, "This is the only way to log in, set a password before unlinking the identity provider")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteUserIdentity(req.Context(), database.DeleteUserIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+	})
+	if err != nil {
+		log.Printf("Error deleting user identity: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if deleted == 0 {
+		responseError(resp, req, 404, errCodeIdentityNotFound, "The identity provider is not linked")
+		return
+	}
+	resp.WriteHeader(204)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/oidc"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/oidc/oidctest"
+	"github.com/google/uuid"
+)
+
+func newOIDCTestConfig(t *testing.T) (*apiConfig, *memoryStore, *oidctest.Server) {
+	t.Helper()
+	server, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://chirpy.test/api/oidc/test/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	cfg, store := newTestConfig(t)
+	cfg.oidcProviders = map[string]*oidc.Provider{"test": provider}
+	return cfg, store, server
+}
+
+type oidcCallbackResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	Error        struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+// oidcLogin goes through the login or link flow and returns the response of the callback.
+func oidcLogin(t *testing.T, cfg *apiConfig, server *oidctest.Server, userID uuid.NullUUID) (int, oidcCallbackResponse) {
+	t.Helper()
+	callback, state := oidcAuthorize(t, cfg, server, userID)
+	return oidcCallback(t, cfg, callback, state)
+}
+
+// oidcAuthorize starts the flow and logs in at the provider, and returns the callback URL and the state of the cookie.
+func oidcAuthorize(t *testing.T, cfg *apiConfig, server *oidctest.Server, userID uuid.NullUUID) (*url.URL, string) {
+	t.Helper()
+	authURL, state, err := cfg.startOIDCLogin(context.Background(), "test", cfg.oidcProviders["test"], userID)
+	if err != nil {
+		t.Fatalf("startOIDCLogin() error = %v", err)
+	}
+	callback, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	return callback, state
+}
+
+// oidcCallback calls the callback from a browser with the state cookie, or without it when cookie is empty.
+func oidcCallback(t *testing.T, cfg *apiConfig, callback *url.URL, cookie string) (int, oidcCallbackResponse) {
+	t.Helper()
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.SetPathValue("provider", "test")
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookie})
+	}
+	rec := httptest.NewRecorder()
+	cfg.handlerOIDCCallback(rec, req)
+
+	body := oidcCallbackResponse{}
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("Response is not JSON: %s", rec.Body.String())
+	}
+	return rec.Code, body
+}
+
+func TestHandlerOIDCCallbackSignsUpAndLogsIn(t *testing.T) {
+	cfg, store, server := newOIDCTestConfig(t)
+	server.SetIdentity(oidctest.Identity{Subject: "subject-1", Email: "new@example.com", EmailVerified: true})
+
+	code, first := oidcLogin(t, cfg, server, uuid.NullUUID{})
+	if code != 200 {
+		t.Fatalf("First login: status = %d, code = %q, want 200", code, first.Error.Code)
+	}
+	if first.Email != "new@example.com" || first.RefreshToken == "" {
+		t.Errorf("First login = %+v, want a new user with tokens", first)
+	}
+	gotUserID, err := auth.ValidateJWT(first.Token, cfg.jwtKeys)
+	if err != nil || gotUserID != first.ID {
+		t.Errorf("Access token is for %v, %v, want %v", gotUserID, err, first.ID)
+	}
+
+	code, second := oidcLogin(t, cfg, server, uuid.NullUUID{})
+	if code != 200 || second.ID != first.ID {
+		t.Errorf("Second login: status = %d, user = %v, want 200 and %v", code, second.ID, first.ID)
+	}
+	if len(store.users) != 1 || len(store.identities) != 1 {
+		t.Errorf("Store has %d users and %d identities, want 1 and 1", len(store.users), len(store.identities))
+	}
+}
+
+func TestHandlerOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity oidctest.Identity
+		existing string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Email of an existing user",
+			identity: oidctest.Identity{Subject: "subject-1", Email: "taken@example.com", EmailVerified: true},
+			existing: "taken@example.com",
+			wantCode: 409,
+			wantErr:  errCodeIdentityNotLinked,
+		},
+		{
+			name:     "Unverified email",
+			identity: oidctest.Identity{Subject: "subject-1", Email: "new@example.com"},
+			wantCode: 401,
+			wantErr:  errCodeOIDCLoginFailed,
+		},
+		{
+			name:     "No email",
+			identity: oidctest.Identity{Subject: "subject-1"},
+			wantCode: 401,
+			wantErr:  errCodeOIDCLoginFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, store, server := newOIDCTestConfig(t)
+			if test.existing != "" {
+				store.addExternalUser(test.existing)
+			}
+			server.SetIdentity(test.identity)
+
+			code, body := oidcLogin(t, cfg, server, uuid.NullUUID{})
+			if code != test.wantCode || body.Error.Code != test.wantErr {
+				t.Errorf("handlerOIDCCallback() status = %d, code = %q, want %d %q", code, body.Error.Code, test.wantCode, test.wantErr)
+			}
+			if len(store.identities) != 0 {
+				t.Errorf("Store has %d identities, want none", len(store.identities))
+			}
+		})
+	}
+}
+
+func TestHandlerOIDCCallbackStateOnlyOnce(t *testing.T) {
+	cfg, _, server := newOIDCTestConfig(t)
+	server.SetIdentity(oidctest.Identity{Subject: "subject-1", Email: "new@example.com", EmailVerified: true})
+
+	callback, state := oidcAuthorize(t, cfg, server, uuid.NullUUID{})
+
+	code, _ := oidcCallback(t, cfg, callback, state)
+	if code != 200 {
+		t.Fatalf("First callback: status = %d, want 200", code)
+	}
+	code, body := oidcCallback(t, cfg, callback, state)
+	if code != 400 || body.Error.Code != errCodeInvalidOIDCState {
+		t.Errorf("Replayed callback: status = %d, code = %q, want 400 %q", code, body.Error.Code, errCodeInvalidOIDCState)
+	}
+}
+
+func TestHandlerOIDCCallbackLinks(t *testing.T) {
+	cfg, store, server := newOIDCTestConfig(t)
+	user := store.addExternalUser("user@example.com")
+	other := store.addExternalUser("other@example.com")
+	server.SetIdentity(oidctest.Identity{Subject: "subject-1", Email: "user@example.com", EmailVerified: true})
+
+	code, body := oidcLogin(t, cfg, server, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if code != 200 || body.ID != user.ID || body.Token != "" {
+		t.Fatalf("Link: status = %d, user = %v, token %q, want 200 and %v without tokens", code, body.ID, body.Token, user.ID)
+	}
+
+	code, body = oidcLogin(t, cfg, server, uuid.NullUUID{})
+	if code != 200 || body.ID != user.ID {
+		t.Errorf("Login after link: status = %d, user = %v, want 200 and %v", code, body.ID, user.ID)
+	}
+
+	code, body = oidcLogin(t, cfg, server, uuid.NullUUID{UUID: other.ID, Valid: true})
+	if code != 409 || body.Error.Code != errCodeIdentityAlreadyLinked {
+		t.Errorf("Link to another user: status = %d, code = %q, want 409 %q", code, body.Error.Code, errCodeIdentityAlreadyLinked)
+	}
+}
+
+func TestHandlerOIDCCallbackNeedsStateCookie(t *testing.T) {
+	cfg, store, server := newOIDCTestConfig(t)
+	victim := store.addExternalUser("victim@example.com")
+	server.SetIdentity(oidctest.Identity{Subject: "attacker", Email: "attacker@example.com", EmailVerified: true})
+	_, otherState := oidcAuthorize(t, cfg, server, uuid.NullUUID{})
+
+	tests := []struct {
+		name   string
+		userID uuid.NullUUID
+		cookie func(state string) string
+	}{
+		{
+			name:   "Login without the cookie",
+			cookie: func(state string) string { return "" },
+		},
+		{
+			name:   "Login with the cookie of another login",
+			cookie: func(state string) string { return otherState },
+		},
+		{
+			name:   "Link without the cookie",
+			userID: uuid.NullUUID{UUID: victim.ID, Valid: true},
+			cookie: func(state string) string { return "" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			callback, state := oidcAuthorize(t, cfg, server, test.userID)
+			code, body := oidcCallback(t, cfg, callback, test.cookie(state))
+			if code != 400 || body.Error.Code != errCodeInvalidOIDCState {
+				t.Errorf("handlerOIDCCallback() status = %d, code = %q, want 400 %q", code, body.Error.Code, errCodeInvalidOIDCState)
+			}
+		})
+	}
+	if len(store.users) != 1 || len(store.identities) != 0 {
+		t.Errorf("Store has %d users and %d identities, want only the victim", len(store.users), len(store.identities))
+	}
+}
+
+func TestHandlerUnlinkIdentity(t *testing.T) {
+	cfg, store, _ := newOIDCTestConfig(t)
+	handler := cfg.middlewareAuth(cfg.handlerUnlinkIdentity)
+	external := store.addExternalUser("external@example.com")
+	withPassword := store.addUser(auth.RoleUser)
+	for _, userID := range []uuid.UUID{external.ID, withPassword.ID} {
+		store.CreateUserIdentity(context.Background(), database.CreateUserIdentityParams{UserID: userID, Provider: "test", Subject: userID.String()})
+	}
+
+	tests := []struct {
+		name     string
+		user     database.User
+		provider string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Only identity of a user without a password",
+			user:     external,
+			provider: "test",
+			wantCode: 409,
+			wantErr:  errCodeLastIdentity,
+		},
+		{
+			name:     "Not linked",
+			user:     external,
+			provider: "other",
+			wantCode: 404,
+			wantErr:  errCodeIdentityNotFound,
+		},
+		{
+			name:     "User with a password",
+			user:     withPassword,
+			provider: "test",
+			wantCode: 204,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, test.user, "provider", test.provider, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerUnlinkIdentity() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+	if len(store.identities) != 1 || store.identities[0].UserID != external.ID {
+		t.Errorf("Store has identities %+v, want only the one of the user without a password", store.identities)
+	}
+}
+
+func TestHandlerOIDCLoginSetsStateCookie(t *testing.T) {
+	cfg, _, _ := newOIDCTestConfig(t)
+	req := httptest.NewRequest("GET", "/api/oidc/test/login", nil)
+	req.SetPathValue("provider", "test")
+	rec := httptest.NewRecorder()
+	cfg.handlerOIDCLogin(rec, req)
+	if rec.Code != 302 {
+		t.Fatalf("handlerOIDCLogin() status = %d, want 302", rec.Code)
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Location is not a URL: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || cookies[0].Value != location.Query().Get("state") {
+		t.Fatalf("Cookies = %+v, want the state of %s", cookies, location)
+	}
+	if !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("Cookie = %+v, want HttpOnly and SameSite=Lax", cookies[0])
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/mailer"
+)
+
+const passwordResetExpiration = time.Hour
+
+// passwordResetMailTimeout limits how long saving and sending a reset email can take after the response.
+const passwordResetMailTimeout = time.Minute
+
+// loadMailer sends emails through SMTP_ADDR when it is set; otherwise they are written
+// to MAIL_LOG_FILE, or to the standard output, for local development.
+func loadMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@localhost>"
+	}
+
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mailer.NewSMTP(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+
+	path := os.Getenv("MAIL_LOG_FILE")
+	if path == "" {
+		return mailer.NewLog(os.Stdout, from), nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return mailer.NewLog(os.Stdout, from), err
+	}
+	return mailer.NewLog(file, from), nil
+}
+
+// handlerPasswordReset answers the same for registered and unknown emails,
+// so it cannot be used to find out who has an account.
+func (cfg *apiConfig) handlerPasswordReset(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), sql.NullString{String: params.Email, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		resp.WriteHeader(202)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	// The token and the email are made in the background, so a registered email
+	// does not take longer to answer than an unknown one.
+	go cfg.sendPasswordReset(context.WithoutCancel(req.Context()), user)
+
+	resp.WriteHeader(202)
+}
+
+// sendPasswordReset saves a new reset token of the user and emails it, giving up after passwordResetMailTimeout.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, user database.User) {
+	ctx, cancel := context.WithTimeout(ctx, passwordResetMailTimeout)
+	defer cancel()
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error making password reset token: %s", err)
+		return
+	}
+
+	err = cfg.dbQueries.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetExpiration),
+	})
+	if err != nil {
+		log.Printf("Error creating password reset token: %s", err)
+		return
+	}
+
+	err = cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email.String,
+		Subject: "Reset your Chirpy password",
+		Body:    cfg.passwordResetBody(token),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email: %s", err)
+	}
+}
+
+func (cfg *apiConfig) passwordResetBody(token string) string {
+	link := token
+	if cfg.passwordResetURL != "" {
+		link = cfg.passwordResetURL + token
+	}
+	return fmt.Sprintf("Somebody asked to reset the password of your Chirpy account.\n\n"+
+		"To choose a new password, use this within %s:\n\n%s\n\n"+
+		"If it was not you, ignore this email and your password stays the same.\n",
+		passwordResetExpiration, link)
+}
+
+// handlerPasswordResetConfirm sets the new password and logs the user out everywhere,
+// in case somebody else knew the old password.
+func (cfg *apiConfig) handlerPasswordResetConfirm(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	if !cfg.checkNewPassword(resp, req, params.Password) {
+		return
+	}
+
+	userID, err := cfg.dbQueries.UsePasswordResetToken(req.Context(), auth.HashRefreshToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 400, errCodeInvalidResetToken, "Password reset token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		log.Printf("Error using password reset token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	// The password is hashed only for a valid token, so that guessing tokens does not cost a hash each.
+	hash, err := cfg.passwordHasher.Hash(params.Password)
+	if err != nil {
+		log.Printf("Error hashing password: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	_, err = cfg.dbQueries.UpdatePassword(req.Context(), database.UpdatePasswordParams{
+		ID:             userID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		log.Printf("Error updating password: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	_, err = cfg.dbQueries.RevokeUserSessions(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error revoking sessions: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
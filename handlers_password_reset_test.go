@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/mailer"
+)
+
+// newPasswordResetTestConfig adds a user with the email user@example.com and the password "old password".
+func newPasswordResetTestConfig(t *testing.T) (*apiConfig, *memoryStore, database.User) {
+	t.Helper()
+	cfg, store := newTestConfig(t)
+	cfg.passwordResetURL = "https://chirpy.example.com/reset?token="
+	hash, err := cfg.passwordHasher.Hash("old password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	user := store.addUser(auth.RoleUser)
+	user.Email = sql.NullString{String: "user@example.com", Valid: true}
+	user.HashedPassword = hash
+	store.setUser(user)
+	return cfg, store, user
+}
+
+func callPasswordReset(t *testing.T, cfg *apiConfig, email string) int {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/password-reset", strings.NewReader(`{"email":"`+email+`"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerPasswordReset(rec, req)
+	return rec.Code
+}
+
+func callPasswordResetConfirm(t *testing.T, cfg *apiConfig, token, password string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/password-reset/confirm", strings.NewReader(`{"token":"`+token+`","password":"`+password+`"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerPasswordResetConfirm(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+var resetTokenPattern = regexp.MustCompile(`reset\?token=([0-9a-f]+)`)
+
+func TestHandlerPasswordReset(t *testing.T) {
+	cfg, store, user := newPasswordResetTestConfig(t)
+	mail := cfg.mailer.(*memoryMailer)
+
+	code := callPasswordReset(t, cfg, "nobody@example.com")
+	if code != 202 || len(mail.sent) != 0 {
+		t.Errorf("Unknown email: status = %d, %d messages sent, want 202 and no mail", code, len(mail.sent))
+	}
+
+	code = callPasswordReset(t, cfg, "user@example.com")
+	if code != 202 {
+		t.Fatalf("handlerPasswordReset() status = %d, want 202", code)
+	}
+	msg := mail.next(t)
+	match := resetTokenPattern.FindStringSubmatch(msg.Body)
+	if match == nil || msg.To != "user@example.com" {
+		t.Fatalf("Mail has no reset link for the user: %+v", msg)
+	}
+	token := match[1]
+
+	if _, ok := store.resetTokens[token]; ok {
+		t.Errorf("Password reset token is stored in plaintext")
+	}
+
+	code, _ = callPasswordResetConfirm(t, cfg, token, "")
+	if code != 400 {
+		t.Errorf("Empty password: status = %d, want 400", code)
+	}
+
+	code, _ = callPasswordResetConfirm(t, cfg, token, "new password")
+	if code != 204 {
+		t.Fatalf("handlerPasswordResetConfirm() status = %d, want 204", code)
+	}
+	ok, _ := auth.CheckPasswordHash("new password", store.user(user.ID).HashedPassword)
+	if !ok {
+		t.Errorf("Password was not changed")
+	}
+	if !slices.Contains(store.revoked, user.ID) {
+		t.Errorf("Sessions were not revoked")
+	}
+
+	code, body := callPasswordResetConfirm(t, cfg, token, "another password")
+	if code != 400 || !strings.Contains(body, errCodeInvalidResetToken) {
+		t.Errorf("Used token: status = %d, body = %s, want 400 %q", code, body, errCodeInvalidResetToken)
+	}
+}
+
+func TestHandlerPasswordResetConfirmRejectsTokens(t *testing.T) {
+	cfg, store, user := newPasswordResetTestConfig(t)
+
+	expired := "expired-token"
+	store.CreatePasswordResetToken(context.Background(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashRefreshToken(expired),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(-time.Minute),
+	})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "Expired token",
+			token: expired,
+		},
+		{
+			name:  "Unknown token",
+			token: "unknown-token",
+		},
+		{
+			name:  "No token",
+			token: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, body := callPasswordResetConfirm(t, cfg, test.token, "new password")
+			if code != 400 || !strings.Contains(body, errCodeInvalidResetToken) {
+				t.Errorf("handlerPasswordResetConfirm() status = %d, body = %s, want 400 %q", code, body, errCodeInvalidResetToken)
+			}
+		})
+	}
+}
+
+// blockingMailer holds every message until release is closed, like a slow SMTP server.
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (m blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+func TestHandlerPasswordResetDoesNotWaitForMail(t *testing.T) {
+	cfg, _, _ := newPasswordResetTestConfig(t)
+	mail := blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	cfg.mailer = mail
+
+	done := make(chan int)
+	go func() {
+		done <- callPasswordReset(t, cfg, "user@example.com")
+	}()
+	select {
+	case code := <-done:
+		if code != 202 {
+			t.Errorf("handlerPasswordReset() status = %d, want 202", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handlerPasswordReset() waits for the email to be sent")
+	}
+
+	close(mail.release)
+	select {
+	case msg := <-mail.sent:
+		if msg.To != "user@example.com" {
+			t.Errorf("Mail is sent to %q, want user@example.com", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No message was sent")
+	}
+}
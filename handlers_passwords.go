@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// checkNewPassword applies the password policy to a password that is about to be set,
+// writing a 400 response when it is not accepted.
+func (cfg *apiConfig) checkNewPassword(resp http.ResponseWriter, req *http.Request, password string) bool {
+	if password == "" {
+		responseError(resp, req, 400, errCodeInvalidParameter, "Password is required")
+		return false
+	}
+
+	err := cfg.passwordPolicy.Check(password)
+	if err != nil {
+		type details struct {
+			MinLength int `json:"min_length"`
+			MaxLength int `json:"max_length"`
+		}
+		responseErrorDetails(resp, req, 400, errCodeWeakPassword, err.Error(), details{
+			MinLength: cfg.passwordPolicy.MinLength,
+			MaxLength: cfg.passwordPolicy.MaxLength,
+		})
+		return false
+	}
+	return true
+}
+
+// rehashPassword replaces a hash made with outdated argon2 parameters after a successful login.
+// The old hash still works, so a failure only postpones the upgrade to the next login.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hash, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("Error rehashing password: %s", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.UpdatePassword(ctx, database.UpdatePasswordParams{
+		ID:             userID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		log.Printf("Error saving rehashed password: %s", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerRechirp(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	userID := authUserID(req)
+
+	if !cfg.checkCanPost(resp, req, userID) {
+		return
+	}
+
+	original, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{ID: chirpUUID})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	rechirp, err := cfg.dbQueries.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:      userID,
+		ReferenceID: uuid.NullUUID{UUID: originalChirpID(original.Chirp), Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 409, errCodeAlreadyRechirped, "Chirp is already rechirped")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating rechirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := chirpFromDB(rechirp, 0, false, 0)
+	err = cfg.attachReferences(req.Context(), false, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Error getting referenced chirp: %s", err)
+	}
+	responseJSON(resp, 201, respBody)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerRechirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerRechirp)
+	author := store.addUser(auth.RoleUser)
+	fan := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+	original := store.addChirp(author.ID)
+	code, rec := callAsUser(t, cfg, handler, fan, "chirpID", original.ID.String(), "")
+	if code != 201 {
+		t.Fatalf("handlerRechirp() status = %d, want 201", code)
+	}
+	rechirp := Chirp{}
+	json.Unmarshal(rec.Body.Bytes(), &rechirp)
+
+	tests := []struct {
+		name     string
+		user     database.User
+		chirpID  string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Rechirp again",
+			user:     fan,
+			chirpID:  original.ID.String(),
+			wantCode: 409,
+			wantErr:  errCodeAlreadyRechirped,
+		},
+		{
+			name:     "Rechirp of the own rechirp",
+			user:     fan,
+			chirpID:  rechirp.ID.String(),
+			wantCode: 409,
+			wantErr:  errCodeAlreadyRechirped,
+		},
+		{
+			name:     "Rechirp of a rechirp",
+			user:     other,
+			chirpID:  rechirp.ID.String(),
+			wantCode: 201,
+		},
+		{
+			name:     "Unknown chirp",
+			user:     other,
+			chirpID:  uuid.New().String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			user:     other,
+			chirpID:  "chirp",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, test.user, "chirpID", test.chirpID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerRechirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if code != 201 {
+				return
+			}
+			chirp := Chirp{}
+			json.Unmarshal(rec.Body.Bytes(), &chirp)
+			if chirp.Kind != "rechirp" || chirp.ReferenceID == nil || *chirp.ReferenceID != original.ID {
+				t.Errorf("Rechirp is %q of %v, want a rechirp of the original %v", chirp.Kind, chirp.ReferenceID, original.ID)
+			}
+			if chirp.ReferencedChirp == nil || chirp.ReferencedChirp.ID != original.ID {
+				t.Errorf("ReferencedChirp = %+v, want the original", chirp.ReferencedChirp)
+			}
+		})
+	}
+}
+
+func TestHandlerChirpsQuote(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	author := store.addUser(auth.RoleUser)
+	fan := store.addUser(auth.RoleUser)
+	original := store.addChirp(author.ID)
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerRechirp), fan, "chirpID", original.ID.String(), "")
+	if code != 201 {
+		t.Fatalf("handlerRechirp() status = %d, want 201", code)
+	}
+	rechirp := Chirp{}
+	json.Unmarshal(rec.Body.Bytes(), &rechirp)
+
+	tests := []struct {
+		name     string
+		quoteOf  uuid.UUID
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Quote",
+			quoteOf:  original.ID,
+			wantCode: 201,
+		},
+		{
+			name:     "Quote of a rechirp",
+			quoteOf:  rechirp.ID,
+			wantCode: 201,
+		},
+		{
+			name:     "Unknown chirp",
+			quoteOf:  uuid.New(),
+			wantCode: 400,
+			wantErr:  errCodeQuoteTargetNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chirp, rec := postChirp(t, cfg, fan, `{"body": "So true", "quote_of": "`+test.quoteOf.String()+`"}`)
+			if rec.Code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerChirps() status = %d, code = %q, want %d %q", rec.Code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if rec.Code != 201 {
+				return
+			}
+			if chirp.Kind != "quote" || chirp.ReferenceID == nil || *chirp.ReferenceID != original.ID {
+				t.Errorf("Chirp is %q of %v, want a quote of the original %v", chirp.Kind, chirp.ReferenceID, original.ID)
+			}
+			if chirp.ReferencedChirp == nil || chirp.ReferencedChirp.ID != original.ID {
+				t.Errorf("ReferencedChirp = %+v, want the original", chirp.ReferencedChirp)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const refreshTokenExpiration = 60 * 24 * time.Hour
+
+// startSession makes the first refresh token of a new session, for a login from the device of the request.
+func (cfg *apiConfig) startSession(req *http.Request, userID uuid.UUID) (string, error) {
+	session, err := cfg.dbQueries.CreateSession(req.Context(), database.CreateSessionParams{
+		UserID:    userID,
+		UserAgent: req.UserAgent(),
+		IpAddress: clientIP(req),
+	})
+	if err != nil {
+		return "", err
+	}
+	return cfg.issueRefreshToken(req.Context(), userID, session.ID)
+}
+
+// issueRefreshToken makes the next refresh token of the family, the family ID is the session ID.
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, userID, familyID uuid.UUID) (string, error) {
+	refresh, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = cfg.dbQueries.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(refresh),
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenExpiration),
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return refresh, nil
+}
+
+// handlerRefresh replaces the refresh token with a new one of the same family. A token that
+// was already replaced must have been stolen (or the client is broken), so its whole family is revoked.
+// The token is only used up together with saving the new one, so a refresh that fails can be retried.
+func (cfg *apiConfig) handlerRefresh(resp http.ResponseWriter, req *http.Request) {
+	refreshToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		log.Printf("Error getting Bearer token: %s", err)
+		responseUnauthorized(resp, req)
+		return
+	}
+	tokenHash := auth.HashRefreshToken(refreshToken)
+
+	stored, err := cfg.dbQueries.GetRefreshToken(req.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !refreshTokenUsable(stored)) {
+		cfg.rejectRefreshToken(resp, req, tokenHash)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting Refresh token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	// The access token gets the current role, so role changes apply after the next refresh.
+	user, err := cfg.dbQueries.GetUser(req.Context(), stored.UserID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if !checkNotSuspended(resp, req, user) {
+		return
+	}
+
+	signedToken, err := auth.MakeJWT(user.ID, user.Role, cfg.jwtKeys, accessTokenExpiration)
+	if err != nil {
+		log.Printf("Error making JWT: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating Refresh Token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	_, err = cfg.dbQueries.RotateRefreshToken(req.Context(), database.RotateRefreshTokenParams{
+		TokenHash:    tokenHash,
+		NewTokenHash: auth.HashRefreshToken(newRefreshToken),
+		ExpiresAt:    time.Now().UTC().Add(refreshTokenExpiration),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// another request rotated the token in the meantime
+		cfg.rejectRefreshToken(resp, req, tokenHash)
+		return
+	}
+	if err != nil {
+		log.Printf("Error rotating Refresh token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	err = cfg.dbQueries.TouchSession(req.Context(), database.TouchSessionParams{
+		ID:        stored.FamilyID,
+		UserAgent: req.UserAgent(),
+		IpAddress: clientIP(req),
+	})
+	if err != nil {
+		log.Printf("Error updating session: %s", err)
+	}
+
+	respBody := User{
+		Token:        signedToken,
+		RefreshToken: newRefreshToken,
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+// refreshTokenUsable tells whether the token can still be exchanged for a new one.
+func refreshTokenUsable(token database.RefreshToken) bool {
+	return !token.RotatedAt.Valid && !token.RevokedAt.Valid && token.ExpiresAt.After(time.Now().UTC())
+}
+
+// rejectRefreshToken answers a refresh with a token that cannot be used, revoking its family on reuse.
+func (cfg *apiConfig) rejectRefreshToken(resp http.ResponseWriter, req *http.Request, tokenHash string) {
+	stored, err := cfg.dbQueries.GetRefreshToken(req.Context(), tokenHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting Refresh token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	// Suspending revokes the refresh tokens of the user, the error tells why.
+	if err == nil {
+		user, err := cfg.dbQueries.GetUser(req.Context(), stored.UserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting user: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+		if err == nil && !checkNotSuspended(resp, req, user) {
+			return
+		}
+	}
+
+	if err == nil && stored.RotatedAt.Valid {
+		log.Printf("Refresh token of family %s was reused, revoking the family", stored.FamilyID)
+		err = cfg.dbQueries.RevokeRefreshTokenFamily(req.Context(), stored.FamilyID)
+		if err != nil {
+			log.Printf("Error revoking Refresh token family: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+		responseError(resp, req, 401, errCodeRefreshTokenReused, "Refresh token was already used, log in again")
+		return
+	}
+
+	log.Printf("Refresh token doesn't exist, expired or was revoked")
+	responseError(resp, req, 401, errCodeUnauthorized, "Refresh token is invalid, expired or revoked")
+}
+
+// handlerRevoke logs out: the refresh token and the rest of its family stop working.
+func (cfg *apiConfig) handlerRevoke(resp http.ResponseWriter, req *http.Request) {
+	refreshToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		log.Printf("Error getting Bearer token: %s", err)
+		responseUnauthorized(resp, req)
+		return
+	}
+
+	stored, err := cfg.dbQueries.GetRefreshToken(req.Context(), auth.HashRefreshToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		resp.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting Refresh token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeRefreshTokenFamily(req.Context(), stored.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking Refresh token: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type refreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	Error        struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+func callWithToken(t *testing.T, handler http.HandlerFunc, path, token string) (int, refreshResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	body := refreshResponse{}
+	if rec.Body.Len() > 0 {
+		err := json.Unmarshal(rec.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("Response is not JSON: %s", rec.Body.String())
+		}
+	}
+	return rec.Code, body
+}
+
+func TestHandlerRefreshRotatesToken(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := store.addUser(auth.RoleUser).ID
+	first, err := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+
+	if _, err := store.GetRefreshToken(context.Background(), first); err == nil {
+		t.Errorf("Refresh token is stored in plaintext")
+	}
+
+	code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", first)
+	if code != 200 {
+		t.Fatalf("handlerRefresh() status = %d, want 200", code)
+	}
+	if body.RefreshToken == "" || body.RefreshToken == first {
+		t.Errorf("handlerRefresh() refresh token = %q, want a new token", body.RefreshToken)
+	}
+	gotUserID, err := auth.ValidateJWT(body.Token, cfg.jwtKeys)
+	if err != nil || gotUserID != userID {
+		t.Errorf("Access token is for %v, %v, want %v", gotUserID, err, userID)
+	}
+
+	second := body.RefreshToken
+	code, body = callWithToken(t, cfg.handlerRefresh, "/api/refresh", second)
+	if code != 200 {
+		t.Fatalf("handlerRefresh() with the rotated token status = %d, want 200", code)
+	}
+	third := body.RefreshToken
+
+	firstStored, _ := store.GetRefreshToken(context.Background(), auth.HashRefreshToken(first))
+	thirdStored, _ := store.GetRefreshToken(context.Background(), auth.HashRefreshToken(third))
+	if firstStored.FamilyID != thirdStored.FamilyID {
+		t.Errorf("Rotated tokens are in families %v and %v, want one family", firstStored.FamilyID, thirdStored.FamilyID)
+	}
+}
+
+func TestHandlerRefreshDetectsReuse(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := store.addUser(auth.RoleUser).ID
+	stolen, _ := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+	other, _ := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+
+	_, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", stolen)
+	rotated := body.RefreshToken
+
+	code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", stolen)
+	if code != 401 || body.Error.Code != errCodeRefreshTokenReused {
+		t.Errorf("Reused token: status = %d, code = %q, want 401 %q", code, body.Error.Code, errCodeRefreshTokenReused)
+	}
+
+	code, body = callWithToken(t, cfg.handlerRefresh, "/api/refresh", rotated)
+	if code != 401 || body.Error.Code != errCodeUnauthorized {
+		t.Errorf("Token of the revoked family: status = %d, code = %q, want 401 %q", code, body.Error.Code, errCodeUnauthorized)
+	}
+
+	code, _ = callWithToken(t, cfg.handlerRefresh, "/api/refresh", other)
+	if code != 200 {
+		t.Errorf("Token of another family: status = %d, want 200", code)
+	}
+}
+
+func TestHandlerRefreshRejectsTokens(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := store.addUser(auth.RoleUser).ID
+
+	revoked, _ := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+	code, _ := callWithToken(t, cfg.handlerRevoke, "/api/revoke", revoked)
+	if code != 204 {
+		t.Fatalf("handlerRevoke() status = %d, want 204", code)
+	}
+
+	expired := "expired-token"
+	store.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(expired),
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(-time.Minute),
+		FamilyID:  uuid.New(),
+	})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "Revoked token",
+			token: revoked,
+		},
+		{
+			name:  "Expired token",
+			token: expired,
+		},
+		{
+			name:  "Unknown token",
+			token: "unknown-token",
+		},
+		{
+			name:  "No token",
+			token: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", test.token)
+			if code != 401 || body.Error.Code != errCodeUnauthorized {
+				t.Errorf("handlerRefresh() status = %d, code = %q, want 401 %q", code, body.Error.Code, errCodeUnauthorized)
+			}
+		})
+	}
+}
+
+// failingRotationStore fails to rotate refresh tokens, like a database that goes away mid-request.
+type failingRotationStore struct {
+	*memoryStore
+}
+
+func (s failingRotationStore) RotateRefreshToken(ctx context.Context, arg database.RotateRefreshTokenParams) (database.RefreshToken, error) {
+	return database.RefreshToken{}, errors.New("connection reset")
+}
+
+func TestHandlerRefreshFailureKeepsToken(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	token, _ := cfg.issueRefreshToken(context.Background(), user.ID, uuid.New())
+
+	cfg.dbQueries = failingRotationStore{store}
+	code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", token)
+	if code != 500 {
+		t.Fatalf("handlerRefresh() with a failing store status = %d, want 500", code)
+	}
+
+	suspended := user
+	suspended.SuspendedUntil = sql.NullTime{Time: time.Now().UTC().Add(time.Hour), Valid: true}
+	cfg.dbQueries = store
+	store.setUser(suspended)
+	code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", token)
+	if code != 403 || body.Error.Code != errCodeAccountSuspended {
+		t.Fatalf("handlerRefresh() of a suspended user status = %d, code = %q, want 403 %q", code, body.Error.Code, errCodeAccountSuspended)
+	}
+
+	store.setUser(user)
+	code, body = callWithToken(t, cfg.handlerRefresh, "/api/refresh", token)
+	if code != 200 {
+		t.Errorf("Retry after the failed refreshes: status = %d, code = %q, want 200", code, body.Error.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// purgeInterval is how often deleted chirps are checked for the end of the retention period.
+const purgeInterval = time.Hour
+
+func (cfg *apiConfig) handlerRestoreChirp(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	userID := authUserID(req)
+
+	chirp, err := cfg.dbQueries.GetDeletedChirp(req.Context(), chirpUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Deleted chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting deleted chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	if userID != chirp.UserID {
+		responseError(resp, req, 403, errCodeForbidden, "Only the author can restore the chirp")
+		return
+	}
+
+	if time.Now().UTC().Sub(chirp.DeletedAt.Time) > cfg.chirpRestorePeriod {
+		responseError(resp, req, 403, errCodeRestorePeriodExpired, "Chirps can only be restored within "+cfg.chirpRestorePeriod.String()+" after deleting")
+		return
+	}
+
+	_, err = cfg.dbQueries.RestoreChirp(req.Context(), chirpUUID)
+	if errors.Is(err, sql.ErrNoRows) && chirp.Kind == "rechirp" {
+		responseError(resp, req, 404, errCodeChirpNotFound, "The rechirped chirp was deleted")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Deleted chirp not found")
+		return
+	}
+	if isUniqueViolation(err) {
+		responseError(resp, req, 409, errCodeAlreadyRechirped, "Chirp is already rechirped again")
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	restored, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		ID:       chirpUUID,
+	})
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := chirpFromDB(restored.Chirp, restored.LikeCount, restored.LikedByMe, restored.ReplyCount)
+	err = cfg.attachReferences(req.Context(), false, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+// purgeDeletedChirps deletes for good the chirps that were deleted longer than the retention period ago,
+// checking every purgeInterval until the context is done.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().UTC().Add(-cfg.chirpRetention)
+		purged, err := cfg.dbQueries.PurgeDeletedChirps(ctx, sql.NullTime{Time: cutoff, Valid: true})
+		if err != nil {
+			log.Printf("Error purging deleted chirps: %s", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted chirps", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerDeleteAndRestoreChirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	remove := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerDeleteChirp)
+	restore := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerRestoreChirp)
+	get := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, cfg.handlerGetChirp)
+	author := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+	chirp := store.addChirp(author.ID)
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerRechirp), other, "chirpID", chirp.ID.String(), "")
+	if code != 201 {
+		t.Fatalf("handlerRechirp() status = %d, want 201", code)
+	}
+	rechirp := Chirp{}
+	json.Unmarshal(rec.Body.Bytes(), &rechirp)
+
+	steps := []struct {
+		name        string
+		handler     http.HandlerFunc
+		user        database.User
+		wantCode    int
+		wantErr     string
+		wantVisible bool
+	}{
+		{
+			name:        "Someone else deletes",
+			handler:     remove,
+			user:        other,
+			wantCode:    403,
+			wantErr:     errCodeForbidden,
+			wantVisible: true,
+		},
+		{
+			name:     "Author deletes",
+			handler:  remove,
+			user:     author,
+			wantCode: 204,
+		},
+		{
+			name:     "Author deletes again",
+			handler:  remove,
+			user:     author,
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Someone else restores",
+			handler:  restore,
+			user:     other,
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:        "Author restores",
+			handler:     restore,
+			user:        author,
+			wantCode:    200,
+			wantVisible: true,
+		},
+		{
+			name:        "Author restores again",
+			handler:     restore,
+			user:        author,
+			wantCode:    404,
+			wantErr:     errCodeChirpNotFound,
+			wantVisible: true,
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, step.handler, step.user, "chirpID", chirp.ID.String(), "")
+			if code != step.wantCode || errorCode(rec) != step.wantErr {
+				t.Fatalf("Status = %d, code = %q, want %d %q", code, errorCode(rec), step.wantCode, step.wantErr)
+			}
+			for _, id := range []uuid.UUID{chirp.ID, rechirp.ID} {
+				code, _ := callAsUser(t, cfg, get, other, "chirpID", id.String(), "")
+				if visible := code == 200; visible != step.wantVisible {
+					t.Errorf("Chirp %v visible = %v, want %v", id, visible, step.wantVisible)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerRestoreChirpRefuses(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerRestoreChirp)
+	author := store.addUser(auth.RoleUser)
+	deleted := func(deletedAgo time.Duration, hidden bool) uuid.UUID {
+		chirp := store.addChirp(author.ID)
+		chirp.DeletedAt = sql.NullTime{Time: time.Now().UTC().Add(-deletedAgo), Valid: true}
+		chirp.HiddenAt = sql.NullTime{Time: chirp.CreatedAt, Valid: hidden}
+		store.chirps[chirp.ID] = chirp
+		return chirp.ID
+	}
+
+	tests := []struct {
+		name     string
+		chirpID  string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Restore period passed",
+			chirpID:  deleted(25*time.Hour, false).String(),
+			wantCode: 403,
+			wantErr:  errCodeRestorePeriodExpired,
+		},
+		{
+			name:     "Hidden by a moderator",
+			chirpID:  deleted(time.Hour, true).String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Chirp is not deleted",
+			chirpID:  store.addChirp(author.ID).ID.String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			chirpID:  "chirp",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, author, "chirpID", test.chirpID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerRestoreChirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerRestoreRechirp(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	remove := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerDeleteChirp)
+	restore := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerRestoreChirp)
+	author := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+
+	// rechirp rechirps the chirp as other and returns the ID of the rechirp.
+	rechirp := func(t *testing.T, chirpID uuid.UUID) uuid.UUID {
+		t.Helper()
+		code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerRechirp), other, "chirpID", chirpID.String(), "")
+		if code != 201 {
+			t.Fatalf("handlerRechirp() status = %d, want 201", code)
+		}
+		created := Chirp{}
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		return created.ID
+	}
+	deleteChirp := func(t *testing.T, user database.User, chirpID uuid.UUID) {
+		t.Helper()
+		if code, _ := callAsUser(t, cfg, remove, user, "chirpID", chirpID.String(), ""); code != 204 {
+			t.Fatalf("handlerDeleteChirp() status = %d, want 204", code)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// setup deletes a rechirp of the chirp and returns it.
+		setup    func(t *testing.T, chirpID uuid.UUID) uuid.UUID
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name: "Rechirp of a chirp that is still there",
+			setup: func(t *testing.T, chirpID uuid.UUID) uuid.UUID {
+				id := rechirp(t, chirpID)
+				deleteChirp(t, other, id)
+				return id
+			},
+			wantCode: 200,
+		},
+		{
+			name: "Chirp was rechirped again since",
+			setup: func(t *testing.T, chirpID uuid.UUID) uuid.UUID {
+				id := rechirp(t, chirpID)
+				deleteChirp(t, other, id)
+				rechirp(t, chirpID)
+				return id
+			},
+			wantCode: 409,
+			wantErr:  errCodeAlreadyRechirped,
+		},
+		{
+			name: "Rechirped chirp was deleted",
+			setup: func(t *testing.T, chirpID uuid.UUID) uuid.UUID {
+				id := rechirp(t, chirpID)
+				deleteChirp(t, other, id)
+				deleteChirp(t, author, chirpID)
+				return id
+			},
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chirp := store.addChirp(author.ID)
+			id := test.setup(t, chirp.ID)
+
+			code, rec := callAsUser(t, cfg, restore, other, "chirpID", id.String(), "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerRestoreChirp() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if restored := !store.chirps[id].DeletedAt.Valid; restored != (test.wantCode == 200) {
+				t.Errorf("Rechirp restored = %v, want %v", restored, test.wantCode == 200)
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedChirps(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	author := store.addUser(auth.RoleUser)
+	kept := store.addChirp(author.ID)
+	recent := store.addChirp(author.ID)
+	recent.DeletedAt = sql.NullTime{Time: time.Now().UTC().Add(-time.Hour), Valid: true}
+	store.chirps[recent.ID] = recent
+	old := store.addChirp(author.ID)
+	old.DeletedAt = sql.NullTime{Time: time.Now().UTC().Add(-cfg.chirpRetention - time.Hour), Valid: true}
+	store.chirps[old.ID] = old
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.purgeDeletedChirps(ctx)
+
+	for _, id := range []uuid.UUID{kept.ID, recent.ID} {
+		if _, ok := store.chirps[id]; !ok {
+			t.Errorf("Chirp %v was purged, want it kept", id)
+		}
+	}
+	if _, ok := store.chirps[old.ID]; ok {
+		t.Errorf("Chirp deleted before the retention period was kept, want it purged")
+	}
+}
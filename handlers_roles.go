@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerSetUserRole lets admins promote and demote users. Admins cannot change their own role,
+// so there is always an admin left. The new role works at once, also with the access tokens the user already has.
+func (cfg *apiConfig) handlerSetUserRole(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("Error parsing UserID to UUID: %s", err)
+		responseInvalidID(resp, req, "userID")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		responseError(resp, req, 400, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	if userUUID == authUserID(req) {
+		responseError(resp, req, 403, errCodeForbidden, "Admins cannot change their own role")
+		return
+	}
+
+	user, err := cfg.dbQueries.UpdateUserRole(req.Context(), database.UpdateUserRoleParams{
+		ID:   userUUID,
+		Role: role,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating role: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 200, userFromDB(user))
+}
+
+// bootstrapAdmin promotes the first admin, who can then promote others with handlerSetUserRole.
+// It runs as the bootstrap-admin command of the server binary and refuses to do anything
+// once there is an admin.
+func bootstrapAdmin(ctx context.Context, db *database.Queries, email string) error {
+	if email == "" {
+		return errors.New("Usage: bootstrap-admin <email>")
+	}
+
+	admins, err := db.CountAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return errors.New("There is an admin already, ask them to promote the user")
+	}
+
+	user, err := db.PromoteFirstAdmin(ctx, sql.NullString{String: email, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("There is no user with the email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) is an admin now\n", user.Email.String, user.ID)
+	return nil
+}
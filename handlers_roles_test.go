@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+)
+
+func TestMiddlewareRole(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	tokenFor := func(role string) string {
+		token, err := auth.MakeJWT(user.ID, role, cfg.jwtKeys, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT() error = %v", err)
+		}
+		return token
+	}
+
+	apiToken, _ := auth.MakeAPIToken()
+	store.CreateAPIToken(context.Background(), database.CreateAPITokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashRefreshToken(apiToken),
+		Scopes:    []string{auth.ScopeChirpsRead, auth.ScopeChirpsWrite, auth.ScopeFollowsWrite},
+	})
+
+	tests := []struct {
+		name     string
+		role     string
+		required string
+		token    string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Admin on an admin route",
+			role:     auth.RoleAdmin,
+			required: auth.RoleAdmin,
+			token:    tokenFor(auth.RoleAdmin),
+			wantCode: 200,
+		},
+		{
+			name:     "Admin on a moderator route",
+			role:     auth.RoleAdmin,
+			required: auth.RoleModerator,
+			token:    tokenFor(auth.RoleAdmin),
+			wantCode: 200,
+		},
+		{
+			name:     "Moderator on an admin route",
+			role:     auth.RoleModerator,
+			required: auth.RoleAdmin,
+			token:    tokenFor(auth.RoleModerator),
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Demoted admin with an old token",
+			role:     auth.RoleUser,
+			required: auth.RoleAdmin,
+			token:    tokenFor(auth.RoleAdmin),
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Promoted user with an old token",
+			role:     auth.RoleModerator,
+			required: auth.RoleModerator,
+			token:    tokenFor(auth.RoleUser),
+			wantCode: 200,
+		},
+		{
+			name:     "API token with every scope",
+			role:     auth.RoleAdmin,
+			required: auth.RoleModerator,
+			token:    apiToken,
+			wantCode: 403,
+			wantErr:  errCodeInsufficientScope,
+		},
+		{
+			name:     "No token",
+			role:     auth.RoleAdmin,
+			required: auth.RoleModerator,
+			token:    "",
+			wantCode: 401,
+			wantErr:  errCodeUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user.Role = test.role
+			store.setUser(user)
+			handler := cfg.middlewareRole(test.required, func(resp http.ResponseWriter, req *http.Request) {
+				resp.WriteHeader(200)
+			})
+			code, body := callWithToken(t, handler, "/admin/test", test.token)
+			if code != test.wantCode || body.Error.Code != test.wantErr {
+				t.Errorf("Status = %d, code = %q, want %d %q", code, body.Error.Code, test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
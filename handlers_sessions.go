@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+type SessionsList struct {
+	Sessions []Session `json:"sessions"`
+}
+
+func (cfg *apiConfig) handlerGetSessions(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
+
+	sessions, err := cfg.dbQueries.GetActiveSessions(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting sessions: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := SessionsList{Sessions: make([]Session, len(sessions))}
+	for i, session := range sessions {
+		respBody.Sessions[i] = Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+		}
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(resp http.ResponseWriter, req *http.Request) {
+	sessionUUID, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		log.Printf("Error parsing SessionID to UUID: %s", err)
+		responseInvalidID(resp, req, "sessionID")
+		return
+	}
+
+	userID := authUserID(req)
+
+	_, err = cfg.dbQueries.RevokeSession(req.Context(), database.RevokeSessionParams{
+		ID:     sessionUUID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking session: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// handlerRevokeSessions logs the user out everywhere, including the session of the request.
+func (cfg *apiConfig) handlerRevokeSessions(resp http.ResponseWriter, req *http.Request) {
+	userID := authUserID(req)
+
+	_, err := cfg.dbQueries.RevokeUserSessions(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error revoking sessions: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// clientIP is the address the request came from; the server is expected to be reached directly, not through a proxy.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// startTestSession logs the user in from the user agent and returns the refresh token and the session ID.
+func startTestSession(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User, userAgent string) (string, uuid.UUID) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/login", nil)
+	req.Header.Set("User-Agent", userAgent)
+	refresh, err := cfg.startSession(req, user.ID)
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	token, err := store.GetRefreshToken(context.Background(), auth.HashRefreshToken(refresh))
+	if err != nil {
+		t.Fatalf("GetRefreshToken() error = %v", err)
+	}
+	return refresh, token.FamilyID
+}
+
+func getSessions(t *testing.T, cfg *apiConfig, user database.User) []Session {
+	t.Helper()
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerGetSessions), user, "", "", "")
+	if code != 200 {
+		t.Fatalf("handlerGetSessions() status = %d, want 200: %s", code, rec.Body.String())
+	}
+	body := SessionsList{}
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("Response is not JSON: %s", rec.Body.String())
+	}
+	return body.Sessions
+}
+
+func sessionIDs(sessions []Session) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	return ids
+}
+
+func TestHandlerGetSessions(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+
+	_, laptop := startTestSession(t, cfg, store, user, "laptop")
+	phoneRefresh, phone := startTestSession(t, cfg, store, user, "phone")
+	_, revoked := startTestSession(t, cfg, store, user, "old phone")
+	startTestSession(t, cfg, store, other, "other")
+
+	if code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", phoneRefresh); code != 200 {
+		t.Fatalf("handlerRefresh() status = %d, want 200", code)
+	}
+	store.RevokeSession(context.Background(), database.RevokeSessionParams{ID: revoked, UserID: user.ID})
+
+	sessions := getSessions(t, cfg, user)
+	if got, want := sessionIDs(sessions), []uuid.UUID{phone, laptop}; !equalIDs(got, want) {
+		t.Fatalf("Sessions = %v, want %v (the refreshed one first)", got, want)
+	}
+	if sessions[1].UserAgent != "laptop" || sessions[1].IPAddress != "192.0.2.1" {
+		t.Errorf("Session = %+v, want the user agent and IP address of the login", sessions[1])
+	}
+
+	if sessions := getSessions(t, cfg, store.addUser(auth.RoleUser)); len(sessions) != 0 {
+		t.Errorf("Sessions of a new user = %v, want none", sessionIDs(sessions))
+	}
+}
+
+func TestHandlerRevokeSession(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+
+	tests := []struct {
+		name      string
+		sessionID func(own, others uuid.UUID) string
+		wantCode  int
+		wantErr   string
+		// wantOwn tells whether the user's session still works afterwards.
+		wantOwn bool
+	}{
+		{
+			name:      "Own session",
+			sessionID: func(own, others uuid.UUID) string { return own.String() },
+			wantCode:  204,
+		},
+		{
+			name:      "Session of another user",
+			sessionID: func(own, others uuid.UUID) string { return others.String() },
+			wantCode:  404,
+			wantErr:   errCodeSessionNotFound,
+			wantOwn:   true,
+		},
+		{
+			name:      "Unknown session",
+			sessionID: func(own, others uuid.UUID) string { return uuid.NewString() },
+			wantCode:  404,
+			wantErr:   errCodeSessionNotFound,
+			wantOwn:   true,
+		},
+		{
+			name:      "Invalid ID",
+			sessionID: func(own, others uuid.UUID) string { return "laptop" },
+			wantCode:  400,
+			wantErr:   errCodeInvalidID,
+			wantOwn:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ownRefresh, own := startTestSession(t, cfg, store, user, "laptop")
+			othersRefresh, others := startTestSession(t, cfg, store, other, "laptop")
+
+			code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerRevokeSession), user, "sessionID", test.sessionID(own, others), "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerRevokeSession() = %d %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+
+			if code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", ownRefresh); (code == 200) != test.wantOwn {
+				t.Errorf("Refreshing the own session: status = %d, want it to work %v", code, test.wantOwn)
+			}
+			if code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", othersRefresh); code != 200 {
+				t.Errorf("Refreshing the session of the other user: status = %d, want 200", code)
+			}
+		})
+	}
+
+	t.Run("Revoked twice", func(t *testing.T) {
+		_, own := startTestSession(t, cfg, store, user, "laptop")
+		handler := cfg.middlewareAuth(cfg.handlerRevokeSession)
+		callAsUser(t, cfg, handler, user, "sessionID", own.String(), "")
+		code, rec := callAsUser(t, cfg, handler, user, "sessionID", own.String(), "")
+		if code != 404 || errorCode(rec) != errCodeSessionNotFound {
+			t.Errorf("handlerRevokeSession() = %d %q, want 404 %q", code, errorCode(rec), errCodeSessionNotFound)
+		}
+	})
+}
+
+func TestHandlerRevokeSessions(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+
+	laptopRefresh, _ := startTestSession(t, cfg, store, user, "laptop")
+	phoneRefresh, _ := startTestSession(t, cfg, store, user, "phone")
+	othersRefresh, others := startTestSession(t, cfg, store, other, "laptop")
+
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerRevokeSessions), user, "", "", "")
+	if code != 204 {
+		t.Fatalf("handlerRevokeSessions() status = %d, want 204: %s", code, rec.Body.String())
+	}
+
+	if sessions := getSessions(t, cfg, user); len(sessions) != 0 {
+		t.Errorf("Sessions after logging out everywhere = %v, want none", sessionIDs(sessions))
+	}
+	for _, refresh := range []string{laptopRefresh, phoneRefresh} {
+		if code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", refresh); code != 401 {
+			t.Errorf("Refreshing a revoked session: status = %d, want 401", code)
+		}
+	}
+
+	if got := sessionIDs(getSessions(t, cfg, other)); !equalIDs(got, []uuid.UUID{others}) {
+		t.Errorf("Sessions of the other user = %v, want %v", got, others)
+	}
+	if code, _ := callWithToken(t, cfg.handlerRefresh, "/api/refresh", othersRefresh); code != 200 {
+		t.Errorf("Refreshing the session of the other user: status = %d, want 200", code)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Actions on accounts recorded in moderation_actions, next to the actions on reports.
+const (
+	moderationBan            = "ban"
+	moderationLiftSuspension = "lift_suspension"
+)
+
+// userSuspended reports whether the user is banned or suspended right now.
+func userSuspended(user database.User) bool {
+	return user.Banned || (user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC()))
+}
+
+// checkNotSuspended writes a 403 response for banned users and users who are suspended right now.
+func checkNotSuspended(resp http.ResponseWriter, req *http.Request, user database.User) bool {
+	if !userSuspended(user) {
+		return true
+	}
+	if user.Banned {
+		responseError(resp, req, 403, errCodeAccountBanned, "The account is banned")
+		return false
+	}
+
+	type details struct {
+		SuspendedUntil time.Time `json:"suspended_until"`
+	}
+	message := "The account is suspended until " + user.SuspendedUntil.Time.Format(time.RFC3339)
+	responseErrorDetails(resp, req, 403, errCodeAccountSuspended, message, details{SuspendedUntil: user.SuspendedUntil.Time})
+	return false
+}
+
+// issuedBeforeSuspension reports whether a token of the user was issued before the user was last
+// suspended or banned. Access tokens only have whole seconds, so both times are compared in whole
+// seconds, and tokens of the second of the suspension are refused too: they may be from before it.
+func issuedBeforeSuspension(user database.User, issuedAt time.Time) bool {
+	return user.SuspendedAt.Valid && !issuedAt.Truncate(time.Second).After(user.SuspendedAt.Time.Truncate(time.Second))
+}
+
+// suspendUser suspends the user until the time, or bans them when ban is true, and revokes all
+// their refresh tokens. Their access and API tokens are refused from now on by issuedBeforeSuspension.
+func (cfg *apiConfig) suspendUser(ctx context.Context, userID uuid.UUID, ban bool, until time.Time) (database.User, error) {
+	var user database.User
+	var err error
+	if ban {
+		user, err = cfg.dbQueries.BanUser(ctx, userID)
+	} else {
+		user, err = cfg.dbQueries.SuspendUser(ctx, database.SuspendUserParams{
+			ID:             userID,
+			SuspendedUntil: sql.NullTime{Time: until.UTC(), Valid: true},
+		})
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	_, err = cfg.dbQueries.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// getSuspensionTarget loads the user of the path for handlerSuspendUser and handlerLiftSuspension.
+// Moderators and admins cannot be suspended, an admin has to change their role first.
+func (cfg *apiConfig) getSuspensionTarget(resp http.ResponseWriter, req *http.Request) (database.User, bool) {
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("Error parsing UserID to UUID: %s", err)
+		responseInvalidID(resp, req, "userID")
+		return database.User{}, false
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeUserNotFound, "User not found")
+		return database.User{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return database.User{}, false
+	}
+
+	if auth.HasRole(user.Role, auth.RoleModerator) {
+		responseError(resp, req, 403, errCodeForbidden, "Moderators and admins cannot be suspended")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// requireAdmin writes the same 403 response as middlewareRole for the parts of
+// moderator endpoints that only admins can use.
+func requireAdmin(resp http.ResponseWriter, req *http.Request, message string) bool {
+	if auth.HasRole(authRole(req), auth.RoleAdmin) {
+		return true
+	}
+	type details struct {
+		RequiredRole string `json:"required_role"`
+	}
+	responseErrorDetails(resp, req, 403, errCodeForbidden, message, details{RequiredRole: auth.RoleAdmin})
+	return false
+}
+
+// handlerSuspendUser suspends the user until suspended_until, or bans them for good
+// with "banned": true, which only admins can do.
+func (cfg *apiConfig) handlerSuspendUser(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		SuspendedUntil *time.Time `json:"suspended_until"`
+		Banned         bool       `json:"banned"`
+		Note           string     `json:"note"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	if params.Banned == (params.SuspendedUntil != nil) {
+		responseError(resp, req, 400, errCodeInvalidParameter, "Either suspended_until or banned is required")
+		return
+	}
+	if params.SuspendedUntil != nil && !params.SuspendedUntil.After(time.Now()) {
+		responseError(resp, req, 400, errCodeInvalidParameter, "suspended_until must be in the future")
+		return
+	}
+	if utf8.RuneCountInString(params.Note) > maxReportDetailsLength {
+		responseError(resp, req, 400, errCodeInvalidParameter, "note can have up to 1000 characters")
+		return
+	}
+	if params.Banned && !requireAdmin(resp, req, "Only admins can ban users") {
+		return
+	}
+
+	target, ok := cfg.getSuspensionTarget(resp, req)
+	if !ok {
+		return
+	}
+
+	action := moderationSuspend
+	var until time.Time
+	if params.Banned {
+		action = moderationBan
+	} else {
+		until = *params.SuspendedUntil
+	}
+	user, err := cfg.suspendUser(req.Context(), target.ID, params.Banned, until)
+	if err != nil {
+		log.Printf("Error suspending user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	err = cfg.recordAccountAction(req, action, user.ID, params.Note)
+	if err != nil {
+		log.Printf("Error recording moderation action: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, userFromDB(user))
+}
+
+// handlerLiftSuspension ends the suspension or the ban of the user; only admins lift bans.
+// The user has to log in again, the old tokens stay revoked.
+func (cfg *apiConfig) handlerLiftSuspension(resp http.ResponseWriter, req *http.Request) {
+	target, ok := cfg.getSuspensionTarget(resp, req)
+	if !ok {
+		return
+	}
+	if target.Banned && !requireAdmin(resp, req, "Only admins can lift bans") {
+		return
+	}
+
+	user, err := cfg.dbQueries.LiftUserSuspension(req.Context(), target.ID)
+	if err != nil {
+		log.Printf("Error lifting suspension: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	err = cfg.recordAccountAction(req, moderationLiftSuspension, user.ID, "")
+	if err != nil {
+		log.Printf("Error recording moderation action: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	responseJSON(resp, 200, userFromDB(user))
+}
+
+// recordAccountAction adds an action on an account, which has no report or chirp, to moderation_actions.
+func (cfg *apiConfig) recordAccountAction(req *http.Request, action string, userID uuid.UUID, note string) error {
+	_, err := cfg.dbQueries.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: authUserID(req), Valid: true},
+		Action:      action,
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		Note:        note,
+	})
+	return err
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestMiddlewareAuthRefusesSuspendedUsers(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	now := time.Now().UTC()
+	handler := cfg.middlewareAuth(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(204)
+	})
+
+	tests := []struct {
+		name     string
+		user     database.User
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Active user",
+			user:     database.User{ID: uuid.New()},
+			wantCode: 204,
+		},
+		{
+			name: "Suspended user",
+			user: database.User{
+				ID:             uuid.New(),
+				SuspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+				SuspendedAt:    sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+			},
+			wantCode: 403,
+			wantErr:  errCodeAccountSuspended,
+		},
+		{
+			name: "Banned user",
+			user: database.User{
+				ID:          uuid.New(),
+				Banned:      true,
+				SuspendedAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+			},
+			wantCode: 403,
+			wantErr:  errCodeAccountBanned,
+		},
+		{
+			name: "Token issued before the suspension",
+			user: database.User{
+				ID:             uuid.New(),
+				SuspendedUntil: sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+				SuspendedAt:    sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+			},
+			wantCode: 401,
+			wantErr:  errCodeUnauthorized,
+		},
+		{
+			name: "Token issued after the suspension",
+			user: database.User{
+				ID:             uuid.New(),
+				SuspendedUntil: sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+				SuspendedAt:    sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+			},
+			wantCode: 204,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.user.Role = auth.RoleUser
+			store.users[test.user.ID] = test.user
+			token, err := auth.MakeJWT(test.user.ID, test.user.Role, cfg.jwtKeys, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			code, body := callWithToken(t, handler, "/api/chirps", token)
+			if code != test.wantCode || body.Error.Code != test.wantErr {
+				t.Errorf("middlewareAuth() status = %d, code = %q, want %d %q", code, body.Error.Code, test.wantCode, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerRefreshRefusesSuspendedUsers(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	userID := uuid.New()
+	kept, _ := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+	revoked, _ := cfg.issueRefreshToken(context.Background(), userID, uuid.New())
+	callWithToken(t, cfg.handlerRevoke, "/api/revoke", revoked)
+
+	until := time.Now().UTC().Add(time.Hour)
+	store.users[userID] = database.User{
+		ID:             userID,
+		Role:           auth.RoleUser,
+		SuspendedUntil: sql.NullTime{Time: until, Valid: true},
+		SuspendedAt:    sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+
+	for _, token := range []string{kept, revoked} {
+		code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", token)
+		if code != 403 || body.Error.Code != errCodeAccountSuspended {
+			t.Errorf("handlerRefresh() status = %d, code = %q, want 403 %q", code, body.Error.Code, errCodeAccountSuspended)
+		}
+	}
+
+	store.users[userID] = database.User{ID: userID, Role: auth.RoleUser, Banned: true}
+	code, body := callWithToken(t, cfg.handlerRefresh, "/api/refresh", kept)
+	if code != 403 || body.Error.Code != errCodeAccountBanned {
+		t.Errorf("handlerRefresh() of a banned user status = %d, code = %q, want 403 %q", code, body.Error.Code, errCodeAccountBanned)
+	}
+}
+
+func TestHandlerSuspendUser(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareRole(auth.RoleModerator, cfg.handlerSuspendUser)
+	moderator := store.addUser(auth.RoleModerator)
+	admin := store.addUser(auth.RoleAdmin)
+	until := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		by         database.User
+		target     database.User
+		body       string
+		wantCode   int
+		wantErr    string
+		wantAction string
+	}{
+		{
+			name:     "Neither suspended_until nor banned",
+			by:       moderator,
+			target:   store.addUser(auth.RoleUser),
+			body:     `{"note": "Spam"}`,
+			wantCode: 400,
+			wantErr:  errCodeInvalidParameter,
+		},
+		{
+			name:     "Both suspended_until and banned",
+			by:       admin,
+			target:   store.addUser(auth.RoleUser),
+			body:     `{"suspended_until": "` + until + `", "banned": true}`,
+			wantCode: 400,
+			wantErr:  errCodeInvalidParameter,
+		},
+		{
+			name:     "Suspended until the past",
+			by:       moderator,
+			target:   store.addUser(auth.RoleUser),
+			body:     `{"suspended_until": "2020-01-01T00:00:00Z"}`,
+			wantCode: 400,
+			wantErr:  errCodeInvalidParameter,
+		},
+		{
+			name:     "Moderator bans",
+			by:       moderator,
+			target:   store.addUser(auth.RoleUser),
+			body:     `{"banned": true}`,
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Moderator suspended",
+			by:       admin,
+			target:   store.addUser(auth.RoleModerator),
+			body:     `{"banned": true}`,
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Unknown user",
+			by:       moderator,
+			target:   database.User{ID: uuid.New()},
+			body:     `{"suspended_until": "` + until + `"}`,
+			wantCode: 404,
+			wantErr:  errCodeUserNotFound,
+		},
+		{
+			name:       "Suspend",
+			by:         moderator,
+			target:     store.addUser(auth.RoleUser),
+			body:       `{"suspended_until": "` + until + `", "note": "Spam"}`,
+			wantCode:   200,
+			wantAction: moderationSuspend,
+		},
+		{
+			name:       "Ban",
+			by:         admin,
+			target:     store.addUser(auth.RoleUser),
+			body:       `{"banned": true}`,
+			wantCode:   200,
+			wantAction: moderationBan,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store.revoked = nil
+			store.actions = nil
+			code, rec := callAsUser(t, cfg, handler, test.by, "userID", test.target.ID.String(), test.body)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerSuspendUser() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+
+			suspended := userSuspended(store.users[test.target.ID])
+			if suspended != (test.wantCode == 200) {
+				t.Errorf("User suspended = %v, want %v", suspended, test.wantCode == 200)
+			}
+			if revoked := len(store.revoked) > 0; revoked != (test.wantCode == 200) {
+				t.Errorf("User's sessions revoked = %v, want %v", revoked, test.wantCode == 200)
+			}
+			if test.wantAction == "" {
+				if len(store.actions) != 0 {
+					t.Errorf("Recorded actions = %+v, want none", store.actions)
+				}
+				return
+			}
+			if len(store.actions) != 1 || store.actions[0].Action != test.wantAction || store.actions[0].UserID.UUID != test.target.ID {
+				t.Errorf("Recorded actions = %+v, want one %q on the user", store.actions, test.wantAction)
+			}
+		})
+	}
+}
+
+func TestHandlerLiftSuspension(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareRole(auth.RoleModerator, cfg.handlerLiftSuspension)
+	moderator := store.addUser(auth.RoleModerator)
+	admin := store.addUser(auth.RoleAdmin)
+
+	suspended := store.addUser(auth.RoleUser)
+	cfg.suspendUser(context.Background(), suspended.ID, false, time.Now().Add(time.Hour))
+	banned := store.addUser(auth.RoleUser)
+	cfg.suspendUser(context.Background(), banned.ID, true, time.Time{})
+
+	tests := []struct {
+		name     string
+		by       database.User
+		target   uuid.UUID
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Moderator lifts a ban",
+			by:       moderator,
+			target:   banned.ID,
+			wantCode: 403,
+			wantErr:  errCodeForbidden,
+		},
+		{
+			name:     "Moderator lifts a suspension",
+			by:       moderator,
+			target:   suspended.ID,
+			wantCode: 200,
+		},
+		{
+			name:     "Admin lifts a ban",
+			by:       admin,
+			target:   banned.ID,
+			wantCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, test.by, "userID", test.target.String(), "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerLiftSuspension() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if stillSuspended := userSuspended(store.users[test.target]); stillSuspended != (test.wantCode != 200) {
+				t.Errorf("User suspended = %v, want %v", stillSuspended, test.wantCode != 200)
+			}
+		})
+	}
+}
+
+func TestIssuedBeforeSuspension(t *testing.T) {
+	suspendedAt := time.Date(2025, 3, 14, 15, 9, 26, 500_000_000, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{
+			name:     "Second before",
+			issuedAt: suspendedAt.Add(-time.Second),
+			want:     true,
+		},
+		{
+			name:     "Same second, just before",
+			issuedAt: suspendedAt.Add(-100 * time.Millisecond),
+			want:     true,
+		},
+		{
+			name:     "Same second, whole seconds like an access token",
+			issuedAt: suspendedAt.Truncate(time.Second),
+			want:     true,
+		},
+		{
+			name:     "Same second, just after",
+			issuedAt: suspendedAt.Add(100 * time.Millisecond),
+			want:     true,
+		},
+		{
+			name:     "Next second",
+			issuedAt: suspendedAt.Add(time.Second).Truncate(time.Second),
+			want:     false,
+		},
+	}
+
+	user := database.User{SuspendedAt: sql.NullTime{Time: suspendedAt, Valid: true}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := issuedBeforeSuspension(user, test.issuedAt); got != test.want {
+				t.Errorf("issuedBeforeSuspension() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if issuedBeforeSuspension(database.User{}, suspendedAt) {
+		t.Errorf("issuedBeforeSuspension() of a user who was never suspended = true, want false")
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// callGet calls the handler without logging in and decodes the JSON response into respBody.
+func callGet(t *testing.T, handler http.HandlerFunc, target string, respBody any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code == 200 {
+		err := json.Unmarshal(rec.Body.Bytes(), respBody)
+		if err != nil {
+			t.Fatalf("Response is not JSON: %s", rec.Body.String())
+		}
+	}
+	return rec
+}
+
+// callAsUser calls the handler behind its middleware, logged in as the user,
+// with the path value and JSON body of the route.
+func callAsUser(t *testing.T, cfg *apiConfig, handler http.HandlerFunc, user database.User, pathName, pathValue, body string) (int, *httptest.ResponseRecorder) {
+	t.Helper()
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if pathName != "" {
+		req.SetPathValue(pathName, pathValue)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code, rec
+}
+
+func errorCode(rec *httptest.ResponseRecorder) string {
+	body := errorEnvelope{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return body.Error.Code
+}
+
+func chirpIDs(chirps []Chirp) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	return ids
+}
+
+func equalIDs(got, want []uuid.UUID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHandlerGetChirpsPages(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	author := store.addUser(auth.RoleUser)
+	other := store.addUser(auth.RoleUser)
+	var mine, all []uuid.UUID
+	for i := 0; i < 5; i++ {
+		chirp := store.addChirp(author.ID)
+		mine = append(mine, chirp.ID)
+		all = append(all, chirp.ID)
+		all = append(all, store.addChirp(other.ID).ID)
+	}
+	reversed := make([]uuid.UUID, len(all))
+	for i, id := range all {
+		reversed[len(all)-1-i] = id
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []uuid.UUID
+	}{
+		{
+			name:  "Oldest first",
+			query: url.Values{"limit": {"3"}},
+			want:  all,
+		},
+		{
+			name:  "Newest first",
+			query: url.Values{"limit": {"4"}, "sort": {"desc"}},
+			want:  reversed,
+		},
+		{
+			name:  "One author",
+			query: url.Values{"limit": {"2"}, "author_id": {author.ID.String()}},
+			want:  mine,
+		},
+		{
+			name:  "Default limit",
+			query: url.Values{},
+			want:  all,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []uuid.UUID{}
+			for pages := 0; ; pages++ {
+				if pages > len(test.want) {
+					t.Fatalf("handlerGetChirps() does not stop paging")
+				}
+				page := ChirpsPage{}
+				rec := callGet(t, cfg.handlerGetChirps, "/api/chirps?"+test.query.Encode(), &page)
+				if rec.Code != 200 {
+					t.Fatalf("handlerGetChirps() status = %d, want 200", rec.Code)
+				}
+				got = append(got, chirpIDs(page.Chirps)...)
+				if page.NextCursor == "" {
+					break
+				}
+				test.query.Set("cursor", page.NextCursor)
+			}
+			if !equalIDs(got, test.want) {
+				t.Errorf("handlerGetChirps() pages = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandlerGetChirpsRejectsParameters(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{
+			name:  "Limit is not a number",
+			query: "limit=ten",
+		},
+		{
+			name:  "Limit too large",
+			query: "limit=101",
+		},
+		{
+			name:  "Cursor is not valid",
+			query: "cursor=abc",
+		},
+		{
+			name:  "Unknown sort",
+			query: "sort=random",
+		},
+		{
+			name:  "Author is not a UUID",
+			query: "author_id=someone",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := callGet(t, cfg.handlerGetChirps, "/api/chirps?"+test.query, &ChirpsPage{})
+			if rec.Code != 400 || errorCode(rec) != errCodeInvalidParameter {
+				t.Errorf("handlerGetChirps() status = %d, code = %q, want 400 %q", rec.Code, errorCode(rec), errCodeInvalidParameter)
+			}
+		})
+	}
+}
+
+func TestHandlerSearchChirpsEscapesSnippet(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	author := store.addUser(auth.RoleUser)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantSnippet string
+	}{
+		{
+			name:        "Plain text",
+			body:        "Chirpy is great",
+			wantSnippet: "Chirpy is <mark>great</mark>",
+		},
+		{
+			name:        "Script tag",
+			body:        `<script>alert("great")</script> great`,
+			wantSnippet: `&lt;script&gt;alert(&#34;<mark>great</mark>&#34;)&lt;/script&gt; great`,
+		},
+		{
+			name:        "Mark tags in the body",
+			body:        "</mark><img src=x onerror=alert(1)> great",
+			wantSnippet: "&lt;/mark&gt;&lt;img src=x onerror=alert(1)&gt; <mark>great</mark>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chirp := store.addChirp(author.ID)
+			store.chirps[chirp.ID] = database.Chirp{ID: chirp.ID, CreatedAt: chirp.CreatedAt, Body: test.body, UserID: author.ID, Kind: "chirp"}
+			defer delete(store.chirps, chirp.ID)
+
+			page := ChirpSearchPage{}
+			rec := callGet(t, cfg.handlerSearchChirps, "/api/chirps/search?q=great", &page)
+			if rec.Code != 200 || len(page.Results) != 1 {
+				t.Fatalf("handlerSearchChirps() status = %d, %d results, want 200 and 1 result", rec.Code, len(page.Results))
+			}
+			if page.Results[0].Snippet != test.wantSnippet {
+				t.Errorf("Snippet = %q, want %q", page.Results[0].Snippet, test.wantSnippet)
+			}
+			if page.Results[0].Body != test.body {
+				t.Errorf("Body = %q, want %q", page.Results[0].Body, test.body)
+			}
+		})
+	}
+}
+
+// postChirp posts the JSON body as the user through handlerChirps and decodes the created chirp.
+func postChirp(t *testing.T, cfg *apiConfig, user database.User, body string) (Chirp, *httptest.ResponseRecorder) {
+	t.Helper()
+	handler := cfg.middlewareScope(auth.ScopeChirpsWrite, cfg.handlerChirps)
+	code, rec := callAsUser(t, cfg, handler, user, "", "", body)
+	chirp := Chirp{}
+	if code == 201 {
+		json.Unmarshal(rec.Body.Bytes(), &chirp)
+	}
+	return chirp, rec
+}
+
+func TestHandlerChirpsLength(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	user := store.addUser(auth.RoleUser)
+	red := store.addUser(auth.RoleUser)
+	red.IsChirpyRed = true
+	store.setUser(red)
+
+	tests := []struct {
+		name       string
+		user       database.User
+		body       string
+		wantCode   int
+		wantLength int
+	}{
+		{
+			name:     "ASCII at the limit",
+			user:     user,
+			body:     strings.Repeat("a", 140),
+			wantCode: 201,
+		},
+		{
+			name:       "ASCII over the limit",
+			user:       user,
+			body:       strings.Repeat("a", 141),
+			wantCode:   400,
+			wantLength: 141,
+		},
+		{
+			name:     "Cyrillic letters",
+			user:     user,
+			body:     strings.Repeat("ж", 140),
+			wantCode: 201,
+		},
+		{
+			name:     "Combining accents",
+			user:     user,
+			body:     strings.Repeat("e\u0301", 140),
+			wantCode: 201,
+		},
+		{
+			name:     "Emoji ZWJ sequences",
+			user:     user,
+			body:     strings.Repeat("👨‍👩‍👧", 140),
+			wantCode: 201,
+		},
+		{
+			name:       "Flags",
+			user:       user,
+			body:       strings.Repeat("🇺🇦", 141),
+			wantCode:   400,
+			wantLength: 141,
+		},
+		{
+			name:     "Chirpy Red limit",
+			user:     red,
+			body:     strings.Repeat("👍🏽", 280),
+			wantCode: 201,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, rec := postChirp(t, cfg, test.user, `{"body": "`+test.body+`"}`)
+			if rec.Code != test.wantCode {
+				t.Fatalf("handlerChirps() status = %d, want %d: %s", rec.Code, test.wantCode, rec.Body.String())
+			}
+			if test.wantCode != 400 {
+				return
+			}
+			body := struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details struct {
+						Length int `json:"length"`
+					} `json:"details"`
+				} `json:"error"`
+			}{}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Error.Code != errCodeChirpTooLong || body.Error.Details.Length != test.wantLength {
+				t.Errorf("Error = %q with length %d, want %q with %d", body.Error.Code, body.Error.Details.Length, errCodeChirpTooLong, test.wantLength)
+			}
+		})
+	}
+}
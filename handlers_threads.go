@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type ThreadReply struct {
+	Chirp
+	Depth int32 `json:"depth"`
+}
+
+type ChirpThread struct {
+	Ancestors  []Chirp       `json:"ancestors"`
+	Chirp      Chirp         `json:"chirp"`
+	Replies    []ThreadReply `json:"replies"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerGetThread(resp http.ResponseWriter, req *http.Request) {
+	chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Error parsing ChirpID to UUID: %s", err)
+		responseInvalidID(resp, req, "chirpID")
+		return
+	}
+
+	viewerID := authViewerID(req)
+	includeHidden := authCanSeeHidden(req)
+
+	limit, cursorCreatedAt, cursorID, ok := parsePage(resp, req)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(req.Context(), database.GetChirpParams{
+		ViewerID:      viewerID,
+		ID:            chirpUUID,
+		IncludeHidden: includeHidden,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	ancestorRows, err := cfg.dbQueries.GetChirpAncestors(req.Context(), database.GetChirpAncestorsParams{
+		ID:            chirpUUID,
+		ViewerID:      viewerID,
+		IncludeHidden: includeHidden,
+	})
+	if err != nil {
+		log.Printf("Error getting chirp ancestors: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	replyRows, err := cfg.dbQueries.GetChirpDescendants(req.Context(), database.GetChirpDescendantsParams{
+		ID:              chirpUUID,
+		ViewerID:        viewerID,
+		IncludeHidden:   includeHidden,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting chirp replies: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	ancestors := make([]Chirp, len(ancestorRows))
+	for i, row := range ancestorRows {
+		ancestors[i] = chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount)
+	}
+
+	nextCursor := ""
+	if len(replyRows) > int(limit) {
+		replyRows = replyRows[:limit]
+		last := replyRows[len(replyRows)-1]
+		nextCursor = pagination.EncodeCursor(last.Chirp.CreatedAt, last.Chirp.ID)
+	}
+
+	replies := make([]ThreadReply, len(replyRows))
+	for i, row := range replyRows {
+		replies[i] = ThreadReply{
+			Chirp: chirpFromDB(row.Chirp, row.LikeCount, row.LikedByMe, row.ReplyCount),
+			Depth: row.Depth,
+		}
+	}
+
+	thread := ChirpThread{
+		Ancestors:  ancestors,
+		Chirp:      chirpFromDB(chirp.Chirp, chirp.LikeCount, chirp.LikedByMe, chirp.ReplyCount),
+		Replies:    replies,
+		NextCursor: nextCursor,
+	}
+
+	refs := append(chirpPointers(thread.Ancestors), &thread.Chirp)
+	for i := range thread.Replies {
+		refs = append(refs, &thread.Replies[i].Chirp)
+	}
+	err = cfg.attachReferences(req.Context(), includeHidden, refs)
+	if err != nil {
+		log.Printf("Error getting referenced chirps: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	responseJSON(resp, 200, thread)
+}
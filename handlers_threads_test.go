@@ -0,0 +1,193 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerChirpsReply(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	author := store.addUser(auth.RoleUser)
+	root := store.addChirp(author.ID)
+
+	tests := []struct {
+		name      string
+		inReplyTo uuid.UUID
+		wantCode  int
+		wantErr   string
+	}{
+		{
+			name:      "Reply",
+			inReplyTo: root.ID,
+			wantCode:  201,
+		},
+		{
+			name:      "Unknown chirp",
+			inReplyTo: uuid.New(),
+			wantCode:  400,
+			wantErr:   errCodeReplyTargetNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chirp, rec := postChirp(t, cfg, author, `{"body": "Reply", "in_reply_to": "`+test.inReplyTo.String()+`"}`)
+			if rec.Code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerChirps() status = %d, code = %q, want %d %q", rec.Code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if test.wantCode == 201 && (chirp.InReplyTo == nil || *chirp.InReplyTo != test.inReplyTo) {
+				t.Errorf("InReplyTo = %v, want %v", chirp.InReplyTo, test.inReplyTo)
+			}
+		})
+	}
+}
+
+func TestHandlerGetThread(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, cfg.handlerGetThread)
+	author := store.addUser(auth.RoleUser)
+	reply := func(parent uuid.UUID) uuid.UUID {
+		t.Helper()
+		chirp, rec := postChirp(t, cfg, author, `{"body": "Reply", "in_reply_to": "`+parent.String()+`"}`)
+		if rec.Code != 201 {
+			t.Fatalf("handlerChirps() status = %d, want 201", rec.Code)
+		}
+		return chirp.ID
+	}
+	root := store.addChirp(author.ID).ID
+	first := reply(root)
+	second := reply(root)
+	nested := reply(first)
+
+	tests := []struct {
+		name          string
+		chirpID       string
+		wantCode      int
+		wantErr       string
+		wantAncestors []uuid.UUID
+		wantReplies   []uuid.UUID
+		wantDepths    []int32
+		wantCount     int64
+	}{
+		{
+			name:          "Root",
+			chirpID:       root.String(),
+			wantCode:      200,
+			wantAncestors: []uuid.UUID{},
+			wantReplies:   []uuid.UUID{first, second, nested},
+			wantDepths:    []int32{1, 1, 2},
+			wantCount:     2,
+		},
+		{
+			name:          "Reply in the middle",
+			chirpID:       first.String(),
+			wantCode:      200,
+			wantAncestors: []uuid.UUID{root},
+			wantReplies:   []uuid.UUID{nested},
+			wantDepths:    []int32{1},
+			wantCount:     1,
+		},
+		{
+			name:          "Leaf",
+			chirpID:       nested.String(),
+			wantCode:      200,
+			wantAncestors: []uuid.UUID{root, first},
+			wantReplies:   []uuid.UUID{},
+			wantDepths:    []int32{},
+		},
+		{
+			name:     "Unknown chirp",
+			chirpID:  uuid.New().String(),
+			wantCode: 404,
+			wantErr:  errCodeChirpNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			chirpID:  "chirp",
+			wantCode: 400,
+			wantErr:  errCodeInvalidID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, author, "chirpID", test.chirpID, "")
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Fatalf("handlerGetThread() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+			if code != 200 {
+				return
+			}
+
+			thread := ChirpThread{}
+			json.Unmarshal(rec.Body.Bytes(), &thread)
+			if got := chirpIDs(thread.Ancestors); !equalIDs(got, test.wantAncestors) {
+				t.Errorf("Ancestors = %v, want %v", got, test.wantAncestors)
+			}
+			replies, depths := []uuid.UUID{}, []int32{}
+			for _, reply := range thread.Replies {
+				replies = append(replies, reply.ID)
+				depths = append(depths, reply.Depth)
+			}
+			if !equalIDs(replies, test.wantReplies) || !slices.Equal(depths, test.wantDepths) {
+				t.Errorf("Replies = %v at depths %v, want %v at %v", replies, depths, test.wantReplies, test.wantDepths)
+			}
+			if thread.Chirp.ReplyCount != test.wantCount {
+				t.Errorf("ReplyCount = %d, want %d", thread.Chirp.ReplyCount, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestHandlerGetThreadHidesHiddenReplies(t *testing.T) {
+	cfg, store := newTestConfig(t)
+	handler := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, cfg.handlerGetThread)
+	author := store.addUser(auth.RoleUser)
+	moderator := store.addUser(auth.RoleModerator)
+	root := store.addChirp(author.ID)
+	reply, _ := postChirp(t, cfg, author, `{"body": "Reply", "in_reply_to": "`+root.ID.String()+`"}`)
+	hidden := store.chirps[reply.ID]
+	hidden.HiddenAt = sql.NullTime{Time: store.now(), Valid: true}
+	store.chirps[reply.ID] = hidden
+
+	tests := []struct {
+		name   string
+		viewer database.User
+		want   []uuid.UUID
+	}{
+		{
+			name:   "User",
+			viewer: author,
+			want:   []uuid.UUID{},
+		},
+		{
+			name:   "Moderator",
+			viewer: moderator,
+			want:   []uuid.UUID{reply.ID},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, handler, test.viewer, "chirpID", root.ID.String(), "")
+			if code != 200 {
+				t.Fatalf("handlerGetThread() status = %d, want 200", code)
+			}
+			thread := ChirpThread{}
+			json.Unmarshal(rec.Body.Bytes(), &thread)
+			replies := []uuid.UUID{}
+			for _, reply := range thread.Replies {
+				replies = append(replies, reply.ID)
+			}
+			if !equalIDs(replies, test.want) {
+				t.Errorf("Replies = %v, want %v", replies, test.want)
+			}
+		})
+	}
+}
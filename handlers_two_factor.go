@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer               = "Chirpy"
+	recoveryCodeCount        = 10
+	loginChallengeExpiration = 5 * time.Minute
+)
+
+type TwoFactorEnrollment struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	RecoveryCodes   []string `json:"recovery_codes"`
+}
+
+// MFAChallenge is the answer to a login with the correct password when two-factor authentication is on.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// handlerEnrollTwoFactor makes a new secret and recovery codes. Two-factor authentication
+// is not on until a code from the app is confirmed with handlerConfirmTwoFactor.
+func (cfg *apiConfig) handlerEnrollTwoFactor(resp http.ResponseWriter, req *http.Request) {
+	if cfg.totpBox == nil {
+		responseError(resp, req, 503, errCodeTwoFactorUnavailable, "Two-factor authentication is not configured on this server")
+		return
+	}
+
+	userID := authUserID(req)
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Error making TOTP secret: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	sealed, err := cfg.totpBox.Seal(secret, userID[:])
+	if err != nil {
+		log.Printf("Error encrypting TOTP secret: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	_, err = cfg.dbQueries.UpsertUserTOTP(req.Context(), database.UpsertUserTOTPParams{
+		UserID: userID,
+		Secret: sealed,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 409, errCodeTwoFactorEnabled, "Two-factor authentication is already on")
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving TOTP secret: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	codes, err := cfg.replaceRecoveryCodes(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error making recovery codes: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := TwoFactorEnrollment{
+		Secret:          totp.EncodeSecret(secret),
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email.String, secret),
+		RecoveryCodes:   codes,
+	}
+	responseJSON(resp, 201, respBody)
+}
+
+// replaceRecoveryCodes stores the hashes of new recovery codes instead of the old ones and returns the codes.
+// The codes are random, so a keyed hash is enough and a code can be looked up with one query.
+func (cfg *apiConfig) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	err := cfg.dbQueries.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = totp.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		err = cfg.dbQueries.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: cfg.totpBox.Digest([]byte(totp.NormalizeRecoveryCode(codes[i]))),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func (cfg *apiConfig) handlerConfirmTwoFactor(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID := authUserID(req)
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	userTOTP, ok := cfg.getUserTOTP(resp, req, userID)
+	if !ok {
+		return
+	}
+	if userTOTP.EnabledAt.Valid {
+		responseError(resp, req, 409, errCodeTwoFactorEnabled, "Two-factor authentication is already on")
+		return
+	}
+
+	valid, err := cfg.checkTOTPCode(req.Context(), userTOTP, params.Code)
+	if err != nil {
+		log.Printf("Error checking TOTP code: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if !valid {
+		responseError(resp, req, 401, errCodeInvalidTwoFactorCode, "Code is not valid")
+		return
+	}
+
+	_, err = cfg.dbQueries.EnableUserTOTP(req.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error enabling TOTP: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// handlerDisableTwoFactor needs a code too, so a stolen access token is not enough to turn it off.
+func (cfg *apiConfig) handlerDisableTwoFactor(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID := authUserID(req)
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	userTOTP, ok := cfg.getUserTOTP(resp, req, userID)
+	if !ok {
+		return
+	}
+
+	if !cfg.reserveLoginAttempt(resp, req, user.Email.String) {
+		return
+	}
+
+	if userTOTP.EnabledAt.Valid {
+		valid, err := cfg.checkSecondFactor(req.Context(), userTOTP, params.Code)
+		if err != nil {
+			log.Printf("Error checking second factor: %s", err)
+			responseInternalError(resp, req)
+			return
+		}
+		if !valid {
+			responseError(resp, req, 401, errCodeInvalidTwoFactorCode, "Code is not valid")
+			return
+		}
+	}
+	cfg.releaseLoginAttempt(req, user.Email.String)
+
+	err = cfg.dbQueries.DeleteUserTOTP(req.Context(), userID)
+	if err != nil {
+		log.Printf("Error deleting TOTP: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *apiConfig) getUserTOTP(resp http.ResponseWriter, req *http.Request, userID uuid.UUID) (database.UserTotp, bool) {
+	if cfg.totpBox == nil {
+		responseError(resp, req, 503, errCodeTwoFactorUnavailable, "Two-factor authentication is not configured on this server")
+		return database.UserTotp{}, false
+	}
+
+	userTOTP, err := cfg.dbQueries.GetUserTOTP(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 409, errCodeTwoFactorNotEnrolled, "Two-factor authentication is not set up")
+		return database.UserTotp{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting TOTP: %s", err)
+		responseInternalError(resp, req)
+		return database.UserTotp{}, false
+	}
+	return userTOTP, true
+}
+
+// checkTOTPCode accepts a code of the app once: the step of the code becomes the last used one.
+func (cfg *apiConfig) checkTOTPCode(ctx context.Context, userTOTP database.UserTotp, code string) (bool, error) {
+	secret, err := cfg.totpBox.Open(userTOTP.Secret, userTOTP.UserID[:])
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	updated, err := cfg.dbQueries.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		UserID:       userTOTP.UserID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+// checkSecondFactor accepts a code of the app or an unused recovery code, which is used up then.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, userTOTP database.UserTotp, code string) (bool, error) {
+	valid, err := cfg.checkTOTPCode(ctx, userTOTP, code)
+	if err != nil || valid {
+		return valid, err
+	}
+
+	used, err := cfg.dbQueries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userTOTP.UserID,
+		CodeHash: cfg.totpBox.Digest([]byte(totp.NormalizeRecoveryCode(code))),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+// startLoginChallenge answers a correct password of a user with two-factor authentication
+// with a token for handlerLoginTwoFactor instead of access and refresh tokens.
+func (cfg *apiConfig) startLoginChallenge(resp http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error making login challenge: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(loginChallengeExpiration)
+	err = cfg.dbQueries.CreateLoginChallenge(req.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error creating login challenge: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	respBody := MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   expiresAt,
+	}
+	responseJSON(resp, 200, respBody)
+}
+
+func (cfg *apiConfig) handlerLoginTwoFactor(resp http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		responseInvalidJSON(resp, req)
+		return
+	}
+
+	tokenHash := auth.HashRefreshToken(params.MFAToken)
+	challenge, err := cfg.dbQueries.GetLoginChallenge(req.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		responseError(resp, req, 401, errCodeUnauthorized, "MFA token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting login challenge: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	// The challenge is used up before the code is checked, so a code is never spent on a challenge
+	// that was used meanwhile, and every challenge gives one try.
+	claimed, err := cfg.dbQueries.ClaimLoginChallenge(req.Context(), tokenHash)
+	if err != nil {
+		log.Printf("Error claiming login challenge: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if claimed == 0 {
+		responseError(resp, req, 401, errCodeUnauthorized, "MFA token is invalid, expired or already used")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(req.Context(), challenge.UserID)
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+
+	userTOTP, ok := cfg.getUserTOTP(resp, req, user.ID)
+	if !ok {
+		return
+	}
+
+	if !cfg.reserveLoginAttempt(resp, req, user.Email.String) {
+		return
+	}
+
+	valid, err := cfg.checkSecondFactor(req.Context(), userTOTP, params.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %s", err)
+		responseInternalError(resp, req)
+		return
+	}
+	if !valid {
+		responseError(resp, req, 401, errCodeInvalidTwoFactorCode, "Code is not valid")
+		return
+	}
+	cfg.releaseLoginAttempt(req, user.Email.String)
+
+	cfg.completeLogin(resp, req, user)
+}
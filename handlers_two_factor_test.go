@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/secretbox"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/totp"
+)
+
+// newTwoFactorTestConfig adds a user with the password "password", without two-factor authentication yet.
+func newTwoFactorTestConfig(t *testing.T) (*apiConfig, *memoryStore, database.User) {
+	t.Helper()
+	cfg, store := newTestConfig(t)
+	box, err := secretbox.New(make([]byte, secretbox.KeySize))
+	if err != nil {
+		t.Fatalf("secretbox.New() error = %v", err)
+	}
+	cfg.totpBox = box
+	hash, err := cfg.passwordHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	user := store.addUser(auth.RoleUser)
+	user.Email = sql.NullString{String: "user@example.com", Valid: true}
+	user.HashedPassword = hash
+	store.setUser(user)
+	return cfg, store, user
+}
+
+// enrollTwoFactor sets up two-factor authentication through the handler and returns the secret
+// from the store and the recovery codes.
+func enrollTwoFactor(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User) ([]byte, []string) {
+	t.Helper()
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerEnrollTwoFactor), user, "", "", "")
+	if code != 201 {
+		t.Fatalf("handlerEnrollTwoFactor() status = %d, want 201", code)
+	}
+	enrollment := TwoFactorEnrollment{}
+	json.Unmarshal(rec.Body.Bytes(), &enrollment)
+
+	userTOTP, err := store.GetUserTOTP(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("GetUserTOTP() error = %v", err)
+	}
+	secret, err := cfg.totpBox.Open(userTOTP.Secret, user.ID[:])
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return secret, enrollment.RecoveryCodes
+}
+
+// enableTwoFactor enrolls the user and confirms with the code of the current step.
+func enableTwoFactor(t *testing.T, cfg *apiConfig, store *memoryStore, user database.User) ([]byte, []string) {
+	t.Helper()
+	secret, codes := enrollTwoFactor(t, cfg, store, user)
+	body := `{"code": "` + totp.Code(secret, totp.Step(time.Now()), totp.Digits) + `"}`
+	code, _ := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerConfirmTwoFactor), user, "", "", body)
+	if code != 204 {
+		t.Fatalf("handlerConfirmTwoFactor() status = %d, want 204", code)
+	}
+	return secret, codes
+}
+
+// startTwoFactorLogin logs in with the password and returns the MFA token.
+func startTwoFactorLogin(t *testing.T, cfg *apiConfig) string {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/login", bytes.NewBufferString(`{"email": "user@example.com", "password": "password"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerLogin(rec, req)
+	challenge := MFAChallenge{}
+	json.Unmarshal(rec.Body.Bytes(), &challenge)
+	if rec.Code != 200 || !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Fatalf("handlerLogin() status = %d, body = %s, want an MFA challenge", rec.Code, rec.Body.String())
+	}
+	return challenge.MFAToken
+}
+
+func callLoginTwoFactor(t *testing.T, cfg *apiConfig, mfaToken, code string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewBufferString(`{"mfa_token": "`+mfaToken+`", "code": "`+code+`"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerLoginTwoFactor(rec, req)
+	return rec
+}
+
+func TestHandlerEnrollTwoFactor(t *testing.T) {
+	cfg, store, user := newTwoFactorTestConfig(t)
+	secret, codes := enrollTwoFactor(t, cfg, store, user)
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("Enrollment has %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	for _, recoveryCode := range store.recoveryCodes {
+		for _, code := range codes {
+			if recoveryCode.CodeHash == code {
+				t.Errorf("Recovery code is stored in plaintext")
+			}
+		}
+	}
+
+	step := totp.Step(time.Now())
+	confirm := cfg.middlewareAuth(cfg.handlerConfirmTwoFactor)
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Recovery code",
+			code:     codes[0],
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Code of the app",
+			code:     totp.Code(secret, step, totp.Digits),
+			wantCode: 204,
+		},
+		{
+			name:     "Confirmed already",
+			code:     totp.Code(secret, step+1, totp.Digits),
+			wantCode: 409,
+			wantErr:  errCodeTwoFactorEnabled,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, confirm, user, "", "", `{"code": "`+test.code+`"}`)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerConfirmTwoFactor() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+
+	code, rec := callAsUser(t, cfg, cfg.middlewareAuth(cfg.handlerEnrollTwoFactor), user, "", "", "")
+	if code != 409 || errorCode(rec) != errCodeTwoFactorEnabled {
+		t.Errorf("Enrolling again: status = %d, code = %q, want 409 %q", code, errorCode(rec), errCodeTwoFactorEnabled)
+	}
+}
+
+func TestHandlerLoginTwoFactor(t *testing.T) {
+	cfg, store, user := newTwoFactorTestConfig(t)
+	secret, codes := enableTwoFactor(t, cfg, store, user)
+	step := totp.Step(time.Now())
+
+	expired := "expired-challenge"
+	store.CreateLoginChallenge(context.Background(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashRefreshToken(expired),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(-time.Minute),
+	})
+	used := startTwoFactorLogin(t, cfg)
+	rec := callLoginTwoFactor(t, cfg, used, totp.Code(secret, step+1, totp.Digits))
+	if rec.Code != 200 {
+		t.Fatalf("handlerLoginTwoFactor() status = %d, want 200", rec.Code)
+	}
+	tokens := User{}
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Errorf("Login has no tokens: %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		mfaToken string
+		code     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Code of the app used for the confirmation",
+			code:     totp.Code(secret, step, totp.Digits),
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Code of the app used for a login",
+			code:     totp.Code(secret, step+1, totp.Digits),
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Recovery code",
+			code:     codes[0],
+			wantCode: 200,
+		},
+		{
+			name:     "Recovery code used before",
+			code:     codes[0],
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Another recovery code",
+			code:     codes[1],
+			wantCode: 200,
+		},
+		{
+			name:     "Expired challenge",
+			mfaToken: expired,
+			code:     codes[2],
+			wantCode: 401,
+			wantErr:  errCodeUnauthorized,
+		},
+		{
+			name:     "Used challenge",
+			mfaToken: used,
+			code:     codes[2],
+			wantCode: 401,
+			wantErr:  errCodeUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mfaToken := test.mfaToken
+			if mfaToken == "" {
+				mfaToken = startTwoFactorLogin(t, cfg)
+			}
+			rec := callLoginTwoFactor(t, cfg, mfaToken, test.code)
+			if rec.Code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerLoginTwoFactor() status = %d, code = %q, want %d %q", rec.Code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+
+	// The code was not spent on the challenge that was used already.
+	rec = callLoginTwoFactor(t, cfg, startTwoFactorLogin(t, cfg), codes[2])
+	if rec.Code != 200 {
+		t.Errorf("Recovery code sent with a used challenge before: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandlerLoginTwoFactorOneTry(t *testing.T) {
+	cfg, store, user := newTwoFactorTestConfig(t)
+	_, codes := enableTwoFactor(t, cfg, store, user)
+
+	mfaToken := startTwoFactorLogin(t, cfg)
+	rec := callLoginTwoFactor(t, cfg, mfaToken, "000000")
+	if rec.Code != 401 || errorCode(rec) != errCodeInvalidTwoFactorCode {
+		t.Fatalf("Wrong code: status = %d, code = %q, want 401 %q", rec.Code, errorCode(rec), errCodeInvalidTwoFactorCode)
+	}
+
+	rec = callLoginTwoFactor(t, cfg, mfaToken, codes[0])
+	if rec.Code != 401 || errorCode(rec) != errCodeUnauthorized {
+		t.Errorf("Second try on the challenge: status = %d, code = %q, want 401 %q", rec.Code, errorCode(rec), errCodeUnauthorized)
+	}
+	rec = callLoginTwoFactor(t, cfg, startTwoFactorLogin(t, cfg), codes[0])
+	if rec.Code != 200 {
+		t.Errorf("Recovery code after the second try: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandlerDisableTwoFactor(t *testing.T) {
+	cfg, store, user := newTwoFactorTestConfig(t)
+	disable := cfg.middlewareAuth(cfg.handlerDisableTwoFactor)
+
+	code, rec := callAsUser(t, cfg, disable, user, "", "", `{"code": "000000"}`)
+	if code != 409 || errorCode(rec) != errCodeTwoFactorNotEnrolled {
+		t.Errorf("Not enrolled: status = %d, code = %q, want 409 %q", code, errorCode(rec), errCodeTwoFactorNotEnrolled)
+	}
+
+	secret, codes := enableTwoFactor(t, cfg, store, user)
+	step := totp.Step(time.Now())
+
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Code of the app used for the confirmation",
+			code:     totp.Code(secret, step, totp.Digits),
+			wantCode: 401,
+			wantErr:  errCodeInvalidTwoFactorCode,
+		},
+		{
+			name:     "Recovery code",
+			code:     codes[0],
+			wantCode: 204,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, rec := callAsUser(t, cfg, disable, user, "", "", `{"code": "`+test.code+`"}`)
+			if code != test.wantCode || errorCode(rec) != test.wantErr {
+				t.Errorf("handlerDisableTwoFactor() status = %d, code = %q, want %d %q", code, errorCode(rec), test.wantCode, test.wantErr)
+			}
+		})
+	}
+
+	if _, err := store.GetUserTOTP(context.Background(), user.ID); err == nil {
+		t.Errorf("TOTP secret was not deleted")
+	}
+	if len(store.recoveryCodes) != 0 {
+		t.Errorf("%d recovery codes were not deleted", len(store.recoveryCodes))
+	}
+}
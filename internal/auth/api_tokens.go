@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes of personal API tokens. Access tokens (JWTs) are allowed everything.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeFollowsWrite = "follows:write"
+)
+
+var scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeFollowsWrite}
+
+// apiTokenPrefix tells API tokens apart from JWTs in the Authorization header,
+// and makes leaked tokens easy to find for secret scanners.
+const apiTokenPrefix = "chirpy_pat_"
+
+// MakeAPIToken makes a personal API token, stored with HashRefreshToken like refresh tokens.
+func MakeAPIToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + token, nil
+}
+
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// APITokenHint is the end of the token that is shown in token lists.
+func APITokenHint(token string) string {
+	if len(token) < 4 {
+		return token
+	}
+	return token[len(token)-4:]
+}
+
+// ParseScopes checks the requested scopes and returns them sorted, without duplicates.
+func ParseScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("At least one scope is required: %s", strings.Join(scopes, ", "))
+	}
+
+	parsed := []string{}
+	for _, scope := range requested {
+		if !slices.Contains(scopes, scope) {
+			return nil, fmt.Errorf("Unknown scope %q, the scopes are %s", scope, strings.Join(scopes, ", "))
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	slices.Sort(parsed)
+	return parsed, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "Sorted without duplicates",
+			requested: []string{ScopeFollowsWrite, ScopeChirpsRead, ScopeFollowsWrite},
+			want:      []string{ScopeChirpsRead, ScopeFollowsWrite},
+		},
+		{
+			name:      "Unknown scope",
+			requested: []string{ScopeChirpsRead, "admin"},
+			wantErr:   true,
+		},
+		{
+			name:      "No scopes",
+			requested: nil,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseScopes(test.requested)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseScopes() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("ParseScopes() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMakeAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatalf("MakeAPIToken() error = %v", err)
+	}
+	if !IsAPIToken(token) {
+		t.Errorf("IsAPIToken(%q) = false, want true", token)
+	}
+	if IsAPIToken("eyJhbGciOiJFZERTQSJ9.e30.sig") {
+		t.Errorf("IsAPIToken() of a JWT = true, want false")
+	}
+	if hint := APITokenHint(token); hint != token[len(token)-4:] {
+		t.Errorf("APITokenHint() = %q, want the last 4 characters", hint)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -66,65 +67,93 @@ func TestCreateAndCheckJWT(t *testing.T) {
 	userUUID := uuid.New()
 	expiresIn, _ := time.ParseDuration("2h")
 	expiresNow, _ := time.ParseDuration("0s")
-	password1 := "password1"
-	password2 := "password2"
-	jwt1, _ := MakeJWT(userUUID, password1, expiresIn)
-	jwt2, _ := MakeJWT(userUUID, password2, expiresNow)
+	key1, _ := GenerateKey(AlgorithmEdDSA, time.Now())
+	key2, _ := GenerateKey(AlgorithmRS256, time.Now())
+	keys1 := NewKeySet(key1)
+	keys2 := NewKeySet(key2)
+	jwt1, _ := MakeJWT(userUUID, RoleUser, keys1, expiresIn)
+	jwt2, _ := MakeJWT(userUUID, RoleUser, keys2, expiresNow)
+	jwt3, _ := MakeJWT(userUUID, RoleUser, keys2, expiresIn)
 
 	tests := []struct {
-		name     string
-		password string
-		jwt      string
-		wantErr  bool
+		name    string
+		keys    *KeySet
+		jwt     string
+		wantErr bool
 	}{
 		{
-			name:     "Correct password",
-			password: password1,
-			jwt:      jwt1,
-			wantErr:  false,
+			name:    "Correct key",
+			keys:    keys1,
+			jwt:     jwt1,
+			wantErr: false,
 		},
 		{
-			name:     "Incorrect password",
-			password: "wrongPassword",
-			jwt:      jwt1,
-			wantErr:  true,
+			name:    "RSA key",
+			keys:    keys2,
+			jwt:     jwt3,
+			wantErr: false,
 		},
 		{
-			name:     "Password doesn't match different jwt",
-			password: password2,
-			jwt:      jwt1,
-			wantErr:  true,
+			name:    "Key doesn't match different jwt",
+			keys:    keys2,
+			jwt:     jwt1,
+			wantErr: true,
 		},
 		{
-			name:     "Empty password",
-			password: "",
-			jwt:      jwt1,
-			wantErr:  true,
+			name:    "Empty key set",
+			keys:    NewKeySet(),
+			jwt:     jwt1,
+			wantErr: true,
 		},
 		{
-			name:     "Invalid jwt",
-			password: password1,
-			jwt:      "invalidjwt",
-			wantErr:  true,
+			name:    "Invalid jwt",
+			keys:    keys1,
+			jwt:     "invalidjwt",
+			wantErr: true,
 		},
 		{
-			name:     "Expired token",
-			password: password2,
-			jwt:      jwt2,
-			wantErr:  true,
+			name:    "Expired token",
+			keys:    keys2,
+			jwt:     jwt2,
+			wantErr: true,
+		},
+		{
+			name:    "HS256 token",
+			keys:    keys1,
+			jwt:     hs256Token(t, userUUID, key1.ID),
+			wantErr: true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := ValidateJWT(test.jwt, test.password)
+			got, err := ValidateJWT(test.jwt, test.keys)
 			if (err != nil) != test.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, test.wantErr)
 			}
+			if !test.wantErr && got != userUUID {
+				t.Errorf("ValidateJWT() = %v, want %v", got, userUUID)
+			}
 		})
 	}
 }
 
+// hs256Token signs a token with a shared secret, which must not pass for one of our keys.
+func hs256Token(t *testing.T, userID uuid.UUID, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = kid
+	ss, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return ss
+}
+
 func TestCheckAuthorization(t *testing.T) {
 	//redo completely!
 	req1, _ := http.NewRequest("GET", "", nil)
@@ -166,3 +195,55 @@ func TestCheckAuthorization(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeRefreshToken(t *testing.T) {
+	token1, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() error = %v", err)
+	}
+	token2, _ := MakeRefreshToken()
+
+	if len(token1) != 64 {
+		t.Errorf("MakeRefreshToken() length = %d, want 64", len(token1))
+	}
+	if token1 == token2 {
+		t.Errorf("MakeRefreshToken() returned the same token twice")
+	}
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token1, _ := MakeRefreshToken()
+	token2, _ := MakeRefreshToken()
+
+	tests := []struct {
+		name      string
+		token     string
+		other     string
+		wantEqual bool
+	}{
+		{
+			name:      "Same token",
+			token:     token1,
+			other:     token1,
+			wantEqual: true,
+		},
+		{
+			name:      "Different tokens",
+			token:     token1,
+			other:     token2,
+			wantEqual: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash := HashRefreshToken(test.token)
+			if hash == test.token {
+				t.Errorf("HashRefreshToken() = the token itself")
+			}
+			if (hash == HashRefreshToken(test.other)) != test.wantEqual {
+				t.Errorf("HashRefreshToken() equal = %v, want %v", !test.wantEqual, test.wantEqual)
+			}
+		})
+	}
+}
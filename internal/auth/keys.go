@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey is a private key that signs access tokens, published in the JWKS by its ID (the "kid").
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// KeySet holds the keys for access tokens. The newest key that was published for publishDelay
+// signs new tokens, the older ones only verify the tokens they signed before the rotation, until they retire.
+type KeySet struct {
+	dir          string
+	algorithm    string
+	rotation     time.Duration
+	publishDelay time.Duration
+
+	mu      sync.RWMutex
+	keys    []SigningKey // oldest first
+	signing int          // index of the signing key in keys
+}
+
+// JWK is a public key in the JSON Web Key format, https://www.rfc-editor.org/rfc/rfc7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func ParseAlgorithm(algorithm string) (string, error) {
+	switch algorithm {
+	case "", AlgorithmEdDSA:
+		return AlgorithmEdDSA, nil
+	case AlgorithmRS256:
+		return AlgorithmRS256, nil
+	}
+	return "", fmt.Errorf("Algorithm must be %s or %s", AlgorithmEdDSA, AlgorithmRS256)
+}
+
+// GenerateKey makes a new key with an ID that sorts by creation time.
+func GenerateKey(algorithm string, now time.Time) (SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return SigningKey{}, fmt.Errorf("Unknown algorithm %q", algorithm)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{
+		ID:        now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		Algorithm: algorithm,
+		CreatedAt: now.UTC(),
+		private:   private,
+	}, nil
+}
+
+// NewKeySet makes a key set that lives only in memory and never rotates.
+func NewKeySet(keys ...SigningKey) *KeySet {
+	set := &KeySet{}
+	set.setKeys(keys, time.Now())
+	return set
+}
+
+// LoadKeySet reads the keys of a directory (one PEM file per key, named after its ID),
+// creating the directory and the first key when needed. New keys use the algorithm,
+// and with a non-zero rotation Rotate replaces the signing key that is older than it.
+// A new key only signs after publishDelay, so verifiers that cache the JWKS know it by then.
+func LoadKeySet(dir, algorithm string, rotation, publishDelay time.Duration) (*KeySet, error) {
+	if dir == "" {
+		return nil, errors.New("Key directory is not set")
+	}
+
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{
+		dir:          dir,
+		algorithm:    algorithm,
+		rotation:     rotation,
+		publishDelay: publishDelay,
+	}
+	err = set.Rotate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Rotate rereads the key directory, so keys made by other servers are picked up,
+// adds a new key when the newest one is older than the rotation period,
+// and deletes the keys that stopped signing more than a rotation period ago.
+func (set *KeySet) Rotate(now time.Time) error {
+	if set.dir == "" {
+		return nil
+	}
+
+	keys, err := readKeyDir(set.dir)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 || (set.rotation > 0 && now.Sub(keys[len(keys)-1].CreatedAt) >= set.rotation) {
+		key, err := GenerateKey(set.algorithm, now)
+		if err != nil {
+			return err
+		}
+		err = writeKeyFile(set.dir, key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	if set.rotation > 0 {
+		active := keys[:0]
+		for i, key := range keys {
+			if i < len(keys)-1 && now.Sub(keys[i+1].CreatedAt.Add(set.publishDelay)) >= set.rotation {
+				err = os.Remove(filepath.Join(set.dir, key.ID+".pem"))
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				continue
+			}
+			active = append(active, key)
+		}
+		keys = active
+	}
+
+	set.setKeys(keys, now)
+	return nil
+}
+
+// setKeys picks the newest key published for publishDelay as the signing key. When no key
+// was published that long, like when the first key was just made, the oldest one signs.
+func (set *KeySet) setKeys(keys []SigningKey, now time.Time) {
+	sorted := append([]SigningKey{}, keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	signing := 0
+	for i, key := range sorted {
+		if !key.CreatedAt.Add(set.publishDelay).After(now) {
+			signing = i
+		}
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.keys = sorted
+	set.signing = signing
+}
+
+// SigningKey returns the key that signs new tokens.
+func (set *KeySet) SigningKey() (SigningKey, error) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	if len(set.keys) == 0 {
+		return SigningKey{}, errors.New("There are no signing keys")
+	}
+	return set.keys[set.signing], nil
+}
+
+// VerificationKey returns the public key with the ID.
+func (set *KeySet) VerificationKey(id string) (SigningKey, crypto.PublicKey, error) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	for _, key := range set.keys {
+		if key.ID == id {
+			return key, key.private.Public(), nil
+		}
+	}
+	return SigningKey{}, nil, fmt.Errorf("Unknown key %q", id)
+}
+
+// JWKS returns the public keys that verify tokens, newest first, including the keys that do not sign yet.
+func (set *KeySet) JWKS() JWKS {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(set.keys))}
+	for i := len(set.keys) - 1; i >= 0; i-- {
+		key := set.keys[i]
+		jwk := JWK{
+			Use: "sig",
+			Alg: key.Algorithm,
+			Kid: key.ID,
+		}
+		switch public := key.private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func readKeyDir(dir string) ([]SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []SigningKey{}
+	for _, path := range paths {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Key file %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// readKeyFile accepts PKCS #8 keys and PKCS #1 RSA keys. The creation time comes from
+// the "Created" PEM header of the keys written by Chirpy, or from the file itself.
+func readKeyFile(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, errors.New("No PEM data found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("Unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	key := SigningKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+		key.private = private
+	case *rsa.PrivateKey:
+		key.Algorithm = AlgorithmRS256
+		key.private = private
+	default:
+		return SigningKey{}, errors.New("Only Ed25519 and RSA keys are supported")
+	}
+
+	if created, ok := block.Headers["Created"]; ok {
+		key.CreatedAt, err = time.Parse(time.RFC3339, created)
+		if err != nil {
+			return SigningKey{}, err
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		key.CreatedAt = info.ModTime().UTC()
+	}
+	return key, nil
+}
+
+// writeKeyFile writes to a temporary file first, so other servers never read half of a key.
+func writeKeyFile(dir string, key SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Created": key.CreatedAt.Format(time.RFC3339)},
+		Bytes:   der,
+	})
+
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, key.ID+".pem"))
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLoadKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	rotation := 24 * time.Hour
+	publishDelay := time.Hour
+
+	keys, err := LoadKeySet(dir, AlgorithmEdDSA, rotation, publishDelay)
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+	first, _ := keys.SigningKey()
+	oldToken, _ := MakeJWT(uuid.New(), RoleUser, keys, time.Hour)
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantKeys    int
+		wantNewKey  bool
+		wantOldKeys bool
+	}{
+		{
+			name:        "Before rotation",
+			now:         first.CreatedAt.Add(time.Hour),
+			wantKeys:    1,
+			wantNewKey:  false,
+			wantOldKeys: true,
+		},
+		{
+			name:        "New key is published before it signs",
+			now:         first.CreatedAt.Add(rotation),
+			wantKeys:    2,
+			wantNewKey:  false,
+			wantOldKeys: true,
+		},
+		{
+			name:        "New key signs after the publish delay",
+			now:         first.CreatedAt.Add(rotation + publishDelay),
+			wantKeys:    2,
+			wantNewKey:  true,
+			wantOldKeys: true,
+		},
+		{
+			name:        "Old key retires a rotation period after it stopped signing",
+			now:         first.CreatedAt.Add(2*rotation + publishDelay),
+			wantKeys:    2,
+			wantNewKey:  true,
+			wantOldKeys: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := keys.Rotate(test.now)
+			if err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != test.wantKeys {
+				t.Errorf("JWKS() has %d keys, want %d", len(jwks.Keys), test.wantKeys)
+			}
+
+			signing, _ := keys.SigningKey()
+			if (signing.ID != first.ID) != test.wantNewKey {
+				t.Errorf("SigningKey() = %s, first key %s, want new key %v", signing.ID, first.ID, test.wantNewKey)
+			}
+
+			_, _, err = keys.VerificationKey(first.ID)
+			if (err == nil) != test.wantOldKeys {
+				t.Errorf("VerificationKey(first) error = %v, want old key %v", err, test.wantOldKeys)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+			if len(files) != len(jwks.Keys) {
+				t.Errorf("Key directory has %d files, want %d", len(files), len(jwks.Keys))
+			}
+		})
+	}
+
+	_, err = ValidateJWT(oldToken, keys)
+	if err == nil {
+		t.Errorf("ValidateJWT() with a retired key error = nil, want error")
+	}
+}
+
+func TestLoadKeySetSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	keys1, err := LoadKeySet(dir, AlgorithmRS256, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+	keys2, err := LoadKeySet(dir, AlgorithmRS256, 0, 0)
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+
+	userID := uuid.New()
+	token, err := MakeJWT(userID, RoleUser, keys1, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	got, err := ValidateJWT(token, keys2)
+	if err != nil || got != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", got, err, userID)
+	}
+
+	jwks := keys2.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].Alg != AlgorithmRS256 || jwks.Keys[0].N == "" {
+		t.Errorf("JWKS() = %+v, want one RSA key", jwks)
+	}
+}
+
+func TestLoadKeySetInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	_, err = LoadKeySet(dir, AlgorithmEdDSA, 0, 0)
+	if err == nil {
+		t.Errorf("LoadKeySet() error = nil, want error")
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Default algorithm",
+			algorithm: "",
+			want:      AlgorithmEdDSA,
+		},
+		{
+			name:      "RSA",
+			algorithm: "RS256",
+			want:      AlgorithmRS256,
+		},
+		{
+			name:      "Shared secret",
+			algorithm: "HS256",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseAlgorithm(test.algorithm)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseAlgorithm() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseAlgorithm() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
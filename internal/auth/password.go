@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// PasswordHasher makes argon2id hashes with its parameters. The parameters are stored
+// in every hash, so hashes made with older parameters still check.
+type PasswordHasher struct {
+	params argon2id.Params
+}
+
+func NewPasswordHasher(memory, iterations uint32, parallelism uint8) (*PasswordHasher, error) {
+	if memory < 8*uint32(parallelism) {
+		return nil, errors.New("Argon2 memory must be at least 8 KiB per thread")
+	}
+	if iterations < 1 || parallelism < 1 {
+		return nil, errors.New("Argon2 iterations and parallelism must be at least 1")
+	}
+	params := *argon2id.DefaultParams
+	params.Memory = memory
+	params.Iterations = iterations
+	params.Parallelism = parallelism
+	return &PasswordHasher{params: params}, nil
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	return argon2id.CreateHash(password, &h.params)
+}
+
+// Check also tells whether the hash was made with other parameters and should be replaced
+// with a new hash, which is possible only now that the password is known.
+func (h *PasswordHasher) Check(password, hash string) (bool, bool, error) {
+	match, params, err := argon2id.CheckHash(password, hash)
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, *params != h.params, nil
+}
+
+// PasswordPolicy decides which new passwords are accepted. The length is counted in characters,
+// and passwords from the breached list are refused.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[[sha1.Size]byte]struct{}
+}
+
+// LoadPasswordPolicy reads the breached passwords from the file, if the path is not empty.
+// The file has one password per line, or the SHA-1 of one in hex, optionally followed by ":count"
+// like in the lists of https://haveibeenpwned.com/Passwords. All of it is kept in memory.
+func LoadPasswordPolicy(minLength, maxLength int, breachedFile string) (*PasswordPolicy, error) {
+	if minLength < 1 || maxLength < minLength {
+		return nil, fmt.Errorf("Password length limits %d-%d are not valid", minLength, maxLength)
+	}
+	policy := &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  map[[sha1.Size]byte]struct{}{},
+	}
+	if breachedFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(breachedFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if sum, ok := parseSHA1Line(line); ok {
+			policy.breached[sum] = struct{}{}
+			continue
+		}
+		policy.breached[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	return policy, scanner.Err()
+}
+
+func parseSHA1Line(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+	_, err := hex.Decode(sum[:], []byte(hash))
+	return sum, err == nil
+}
+
+func (p *PasswordPolicy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("Password must be at most %d characters long", p.MaxLength)
+	}
+	if _, ok := p.breached[sha1.Sum([]byte(password))]; ok {
+		return errors.New("Password is in a list of breached passwords, choose another one")
+	}
+	return nil
+}
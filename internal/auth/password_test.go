@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHasherCheck(t *testing.T) {
+	current, err := NewPasswordHasher(16*1024, 2, 1)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error = %v", err)
+	}
+	old, _ := NewPasswordHasher(8*1024, 1, 1)
+
+	currentHash, _ := current.Hash("password1")
+	oldHash, _ := old.Hash("password1")
+
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    bool
+	}{
+		{
+			name:      "Current parameters",
+			password:  "password1",
+			hash:      currentHash,
+			wantMatch: true,
+		},
+		{
+			name:       "Outdated parameters",
+			password:   "password1",
+			hash:       oldHash,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:     "Wrong password with outdated parameters",
+			password: "password2",
+			hash:     oldHash,
+		},
+		{
+			name:     "Invalid hash",
+			password: "password1",
+			hash:     "invalidhash",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, rehash, err := current.Check(test.password, test.hash)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if match != test.wantMatch || rehash != test.wantRehash {
+				t.Errorf("Check() = %v, %v, want %v, %v", match, rehash, test.wantMatch, test.wantRehash)
+			}
+		})
+	}
+
+	if !strings.Contains(currentHash, "m=16384,t=2,p=1") {
+		t.Errorf("Hash() = %s, want the parameters of the hasher", currentHash)
+	}
+}
+
+func TestNewPasswordHasherInvalid(t *testing.T) {
+	_, err := NewPasswordHasher(4, 1, 1)
+	if err == nil {
+		t.Errorf("NewPasswordHasher() with 4 KiB error = nil, want error")
+	}
+	_, err = NewPasswordHasher(64*1024, 0, 1)
+	if err == nil {
+		t.Errorf("NewPasswordHasher() with 0 iterations error = nil, want error")
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	sum := sha1.Sum([]byte("correct horse battery staple"))
+	list := "password123\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":1234\r\n\n"
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(list), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	policy, err := LoadPasswordPolicy(8, 40, path)
+	if err != nil {
+		t.Fatalf("LoadPasswordPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{
+			name:     "Good password",
+			password: "kerfuffle sharbert",
+		},
+		{
+			name:     "Too short",
+			password: "short",
+			wantErr:  true,
+		},
+		{
+			name:     "Length in characters",
+			password: "пароль42",
+		},
+		{
+			name:     "Too long",
+			password: strings.Repeat("a", 41),
+			wantErr:  true,
+		},
+		{
+			name:     "Breached password",
+			password: "password123",
+			wantErr:  true,
+		},
+		{
+			name:     "Breached password from a SHA-1 line",
+			password: "correct horse battery staple",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Check(test.password)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Roles of users, each allowed everything the roles before it are.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func ParseRole(role string) (string, error) {
+	if !slices.Contains(roles, role) {
+		return "", fmt.Errorf("Role must be %s, %s or %s", RoleUser, RoleModerator, RoleAdmin)
+	}
+	return role, nil
+}
+
+// HasRole reports whether the role is the required role or above it. Unknown roles,
+// like the missing role of tokens issued before roles existed, count as users.
+func HasRole(role, required string) bool {
+	return max(slices.Index(roles, role), 0) >= slices.Index(roles, required)
+}
@@ -0,0 +1,52 @@
+package auth
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		required string
+		want     bool
+	}{
+		{
+			name:     "Same role",
+			role:     RoleModerator,
+			required: RoleModerator,
+			want:     true,
+		},
+		{
+			name:     "Higher role",
+			role:     RoleAdmin,
+			required: RoleModerator,
+			want:     true,
+		},
+		{
+			name:     "Lower role",
+			role:     RoleModerator,
+			required: RoleAdmin,
+			want:     false,
+		},
+		{
+			name:     "Missing role is a user",
+			role:     "",
+			required: RoleUser,
+			want:     true,
+		},
+		{
+			name:     "Unknown role is not a moderator",
+			role:     "superuser",
+			required: RoleModerator,
+			want:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := HasRole(test.role, test.required)
+			if got != test.want {
+				t.Errorf("HasRole(%q, %q) = %v, want %v", test.role, test.required, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package contentfilter
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Policy string
+
+const (
+	PolicyMask   Policy = "mask"
+	PolicyReject Policy = "reject"
+	PolicyFlag   Policy = "flag"
+)
+
+const mask = "****"
+
+func ParsePolicy(policy string) (Policy, error) {
+	switch Policy(policy) {
+	case PolicyMask, PolicyReject, PolicyFlag:
+		return Policy(policy), nil
+	}
+	return "", errors.New("Policy must be mask, reject or flag")
+}
+
+// ParseWord normalizes a word for the list and checks that the tokenizer can ever match it.
+func ParseWord(word string) (string, error) {
+	word = normalize(word)
+	if word == "" {
+		return "", errors.New("Word is empty")
+	}
+	for _, r := range word {
+		if !isWordRune(r) {
+			return "", errors.New("Word must consist of letters and numbers only")
+		}
+	}
+	return word, nil
+}
+
+// Filter holds the word list and can be changed while the server is running.
+type Filter struct {
+	mu    sync.RWMutex
+	words map[string]Policy
+}
+
+type Result struct {
+	Text     string
+	Rejected []string
+	Flagged  []string
+}
+
+func New(words map[string]Policy) *Filter {
+	filter := &Filter{words: map[string]Policy{}}
+	for word, policy := range words {
+		filter.words[normalize(word)] = policy
+	}
+	return filter
+}
+
+// LoadFile reads a word list with one "word [policy]" entry per line, the policy defaults to mask.
+// Empty lines and lines starting with # are skipped.
+func LoadFile(path string) (map[string]Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := map[string]Policy{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		policy := PolicyMask
+		if len(fields) > 1 {
+			policy, err = ParsePolicy(fields[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		words[normalize(fields[0])] = policy
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+func (f *Filter) Set(word string, policy Policy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.words[normalize(word)] = policy
+}
+
+func (f *Filter) Remove(word string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.words, normalize(word))
+}
+
+func (f *Filter) Words() map[string]Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	words := make(map[string]Policy, len(f.words))
+	for word, policy := range f.words {
+		words[word] = policy
+	}
+	return words
+}
+
+// Apply masks the words with the mask policy and reports the rejected and flagged ones.
+// Words are runs of letters, numbers and combining marks, so punctuation around them doesn't matter.
+func (f *Filter) Apply(text string) Result {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var cleaned strings.Builder
+	rejected := map[string]bool{}
+	flagged := map[string]bool{}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			cleaned.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(runes) && isWordRune(runes[i]) {
+			i++
+		}
+		word := string(runes[start:i])
+		normalized := normalize(word)
+
+		switch f.words[normalized] {
+		case PolicyMask:
+			cleaned.WriteString(mask)
+			continue
+		case PolicyReject:
+			rejected[normalized] = true
+		case PolicyFlag:
+			flagged[normalized] = true
+		}
+		cleaned.WriteString(word)
+	}
+
+	return Result{
+		Text:     cleaned.String(),
+		Rejected: sortedKeys(rejected),
+		Flagged:  sortedKeys(flagged),
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,162 @@
+package contentfilter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	filter := New(map[string]Policy{
+		"kerfuffle": PolicyMask,
+		"Sharbert":  PolicyMask,
+		"fornax":    PolicyReject,
+		"блин":      PolicyMask,
+		"spam":      PolicyFlag,
+	})
+
+	tests := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantRejected []string
+		wantFlagged  []string
+	}{
+		{
+			name:     "Clean text",
+			text:     "This is a kerfuffled opinion",
+			wantText: "This is a kerfuffled opinion",
+		},
+		{
+			name:     "Word with punctuation",
+			text:     "What a Kerfuffle!",
+			wantText: "What a ****!",
+		},
+		{
+			name:     "Word before a newline",
+			text:     "kerfuffle\nsharbert",
+			wantText: "****\n****",
+		},
+		{
+			name:     "Cyrillic word",
+			text:     "Ну, БЛИН...",
+			wantText: "Ну, ****...",
+		},
+		{
+			name:         "Rejected word",
+			text:         "I hate Fornax.",
+			wantText:     "I hate Fornax.",
+			wantRejected: []string{"fornax"},
+		},
+		{
+			name:        "Flagged word",
+			text:        "spam, spam and eggs",
+			wantText:    "spam, spam and eggs",
+			wantFlagged: []string{"spam"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := filter.Apply(test.text)
+			if result.Text != test.wantText {
+				t.Errorf("Apply() text = %q, want %q", result.Text, test.wantText)
+			}
+			if len(result.Rejected) != len(test.wantRejected) || (len(test.wantRejected) > 0 && !reflect.DeepEqual(result.Rejected, test.wantRejected)) {
+				t.Errorf("Apply() rejected = %v, want %v", result.Rejected, test.wantRejected)
+			}
+			if len(result.Flagged) != len(test.wantFlagged) || (len(test.wantFlagged) > 0 && !reflect.DeepEqual(result.Flagged, test.wantFlagged)) {
+				t.Errorf("Apply() flagged = %v, want %v", result.Flagged, test.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestSetAndRemove(t *testing.T) {
+	filter := New(nil)
+	filter.Set("Fornax", PolicyMask)
+	if got := filter.Apply("fornax").Text; got != "****" {
+		t.Errorf("Apply() after Set = %q, want %q", got, "****")
+	}
+
+	filter.Remove("FORNAX")
+	if got := filter.Apply("fornax").Text; got != "fornax" {
+		t.Errorf("Apply() after Remove = %q, want %q", got, "fornax")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	content := "# filtered words\nkerfuffle\n\nFornax reject\nspam flag\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	want := map[string]Policy{
+		"kerfuffle": PolicyMask,
+		"fornax":    PolicyReject,
+		"spam":      PolicyFlag,
+	}
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("LoadFile() = %v, want %v", words, want)
+	}
+
+	if err := os.WriteFile(path, []byte("kerfuffle shout\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path); err == nil {
+		t.Errorf("LoadFile() with unknown policy error = nil, want error")
+	}
+}
+
+func TestParseWord(t *testing.T) {
+	tests := []struct {
+		name    string
+		word    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Mixed case word",
+			word: "KerFuffle",
+			want: "kerfuffle",
+		},
+		{
+			name: "Cyrillic word",
+			word: "Блин",
+			want: "блин",
+		},
+		{
+			name:    "Empty word",
+			word:    " ",
+			wantErr: true,
+		},
+		{
+			name:    "Two words",
+			word:    "kerfuffle sharbert",
+			wantErr: true,
+		},
+		{
+			name:    "Punctuation",
+			word:    "kerfuffle!",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseWord(test.word)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseWord() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseWord() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
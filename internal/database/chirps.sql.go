@@ -7,29 +7,62 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUserChirpsSince = `-- name: CountUserChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2
+`
+
+type CountUserChirpsSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+// Deleted chirps are counted too, so deleting does not free up the limit.
+func (q *Queries) CountUserChirpsSince(ctx context.Context, arg CountUserChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserChirpsSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, kind, reference_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, kind, reference_id, edited_at, deleted_at, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	InReplyTo   uuid.NullUUID
+	Kind        string
+	ReferenceID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.Kind,
+		arg.ReferenceID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,58 +70,180 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.ReferenceID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, kind, reference_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    'rechirp',
+    $2
+)
+ON CONFLICT (user_id, reference_id) WHERE kind = 'rechirp' AND deleted_at IS NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, kind, reference_id, edited_at, deleted_at, hidden_at
+`
+
+type CreateRechirpParams struct {
+	UserID      uuid.UUID
+	ReferenceID uuid.NullUUID
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.ReferenceID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.ReferenceID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE (id = $1 OR (kind = 'rechirp' AND reference_id = $1))
+AND deleted_at IS NULL
 `
 
+// The chirp is only marked as deleted together with its rechirps, so it can be restored;
+// PurgeDeletedChirps removes it for good later.
 func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, deleteChirp, id)
 	return err
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE chirps.id = $2 AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $3::boolean)
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
-	var i Chirp
+type GetChirpParams struct {
+	ViewerID      uuid.NullUUID
+	ID            uuid.UUID
+	IncludeHidden bool
+}
+
+type GetChirpRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (GetChirpRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ViewerID, arg.ID, arg.IncludeHidden)
+	var i GetChirpRow
 	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
+		&i.Chirp.ID,
+		&i.Chirp.CreatedAt,
+		&i.Chirp.UpdatedAt,
+		&i.Chirp.Body,
+		&i.Chirp.UserID,
+		&i.Chirp.BodyTsv,
+		&i.Chirp.InReplyTo,
+		&i.Chirp.Kind,
+		&i.Chirp.ReferenceID,
+		&i.Chirp.EditedAt,
+		&i.Chirp.DeletedAt,
+		&i.Chirp.HiddenAt,
+		&i.LikeCount,
+		&i.LikedByMe,
+		&i.ReplyCount,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1 FROM chirps AS parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps AS child WHERE child.id = $3)
+    UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1 FROM chirps AS parent
+    JOIN ancestors ON parent.id = ancestors.in_reply_to
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM ancestors
+JOIN chirps ON chirps.id = ancestors.id
+WHERE chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $2::boolean)
+ORDER BY ancestors.depth DESC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpAncestorsParams struct {
+	ViewerID      uuid.NullUUID
+	IncludeHidden bool
+	ID            uuid.UUID
+}
+
+type GetChirpAncestorsRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, arg GetChirpAncestorsParams) ([]GetChirpAncestorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, arg.ViewerID, arg.IncludeHidden, arg.ID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetChirpAncestorsRow
 	for rows.Next() {
-		var i Chirp
+		var i GetChirpAncestorsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Body,
-			&i.UserID,
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
@@ -103,14 +258,109 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsAuthor = `-- name: GetChirpsAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id, depth) AS (
+    SELECT reply.id, 1 FROM chirps AS reply
+    WHERE reply.in_reply_to = $6::uuid
+    UNION ALL
+    SELECT reply.id, descendants.depth + 1 FROM chirps AS reply
+    JOIN descendants ON reply.in_reply_to = descendants.id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count,
+    descendants.depth
+FROM descendants
+JOIN chirps ON chirps.id = descendants.id
+WHERE chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $2::boolean)
+AND ($3::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($3::timestamp, $4::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $5
 `
 
-func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsAuthor, userID)
+type GetChirpDescendantsParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+	ID              uuid.UUID
+}
+
+type GetChirpDescendantsRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+	Depth      int32
+}
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]GetChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+		arg.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpDescendantsRow
+	for rows.Next() {
+		var i GetChirpDescendantsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, kind, reference_id, edited_at, deleted_at, hidden_at FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $2::boolean)
+`
+
+type GetChirpsByIDsParams struct {
+	Ids           []uuid.UUID
+	IncludeHidden bool
+}
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, arg GetChirpsByIDsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(arg.Ids), arg.IncludeHidden)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +374,93 @@ func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.ReferenceID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageAsc = `-- name: GetChirpsPageAsc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $2::boolean)
+AND ($3::uuid IS NULL OR user_id = $3::uuid)
+AND ($4::timestamp IS NULL
+    OR (created_at, id) > ($4::timestamp, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type GetChirpsPageAscParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type GetChirpsPageAscRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+}
+
+func (q *Queries) GetChirpsPageAsc(ctx context.Context, arg GetChirpsPageAscParams) ([]GetChirpsPageAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageAsc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsPageAscRow
+	for rows.Next() {
+		var i GetChirpsPageAscRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
@@ -138,6 +475,206 @@ func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chir
 	return items, nil
 }
 
+const getChirpsPageDesc = `-- name: GetChirpsPageDesc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $2::boolean)
+AND ($3::uuid IS NULL OR user_id = $3::uuid)
+AND ($4::timestamp IS NULL
+    OR (created_at, id) < ($4::timestamp, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type GetChirpsPageDescParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type GetChirpsPageDescRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+}
+
+func (q *Queries) GetChirpsPageDesc(ctx context.Context, arg GetChirpsPageDescParams) ([]GetChirpsPageDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageDesc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsPageDescRow
+	for rows.Next() {
+		var i GetChirpsPageDescRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirp = `-- name: GetDeletedChirp :one
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, kind, reference_id, edited_at, deleted_at, hidden_at FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL AND hidden_at IS NULL
+`
+
+// Hidden chirps are not restored by their authors.
+func (q *Queries) GetDeletedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.ReferenceID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const getTimeline = `-- name: GetTimeline :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $2
+AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $3::boolean)
+AND ($4::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($4::timestamp, $5::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $6
+`
+
+type GetTimelineParams struct {
+	ViewerID        uuid.NullUUID
+	UserID          uuid.UUID
+	IncludeHidden   bool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type GetTimelineRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+}
+
+func (q *Queries) GetTimeline(ctx context.Context, arg GetTimelineParams) ([]GetTimelineRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimeline,
+		arg.ViewerID,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimelineRow
+	for rows.Next() {
+		var i GetTimelineRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetChirps = `-- name: ResetChirps :exec
 DELETE FROM chirps
 `
@@ -146,3 +683,137 @@ func (q *Queries) ResetChirps(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, resetChirps)
 	return err
 }
+
+const restoreChirp = `-- name: RestoreChirp :one
+WITH rechirps AS (
+    UPDATE chirps AS rechirps
+    SET deleted_at = NULL
+    FROM chirps AS original
+    WHERE original.id = $1
+    AND rechirps.kind = 'rechirp' AND rechirps.reference_id = original.id
+    AND rechirps.deleted_at = original.deleted_at
+)
+UPDATE chirps
+SET deleted_at = NULL
+WHERE chirps.id = $1 AND chirps.deleted_at IS NOT NULL
+AND (chirps.kind <> 'rechirp' OR EXISTS (
+    SELECT 1 FROM chirps AS original
+    WHERE original.id = chirps.reference_id AND original.deleted_at IS NULL
+))
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, kind, reference_id, edited_at, deleted_at, hidden_at
+`
+
+// Rechirps deleted together with the chirp come back with it. A rechirp comes back only while
+// its original is not deleted.
+func (q *Queries) RestoreChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.ReferenceID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.kind, chirps.reference_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at,
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count,
+    ts_rank(body_tsv, websearch_to_tsquery('english', $2))::real AS rank,
+    -- matches are marked with control characters that cannot be in the body, the handler escapes the rest
+    ts_headline('english', translate(body, chr(2) || chr(3), ''), websearch_to_tsquery('english', $2),
+        'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2')::text AS snippet
+FROM chirps
+WHERE body_tsv @@ websearch_to_tsquery('english', $2)
+AND deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR $3::boolean)
+AND ($4::uuid IS NULL OR user_id = $4::uuid)
+ORDER BY
+    CASE WHEN $5::text = 'asc' THEN created_at END ASC,
+    CASE WHEN $5::text = 'desc' THEN created_at END DESC,
+    rank DESC, id ASC
+LIMIT $7
+OFFSET $6
+`
+
+type SearchChirpsParams struct {
+	ViewerID      uuid.NullUUID
+	SearchQuery   string
+	IncludeHidden bool
+	AuthorID      uuid.NullUUID
+	Sort          string
+	PageOffset    int32
+	PageLimit     int32
+}
+
+type SearchChirpsRow struct {
+	Chirp      Chirp
+	LikeCount  int64
+	LikedByMe  bool
+	ReplyCount int64
+	Rank       float32
+	Snippet    string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.ViewerID,
+		arg.SearchQuery,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.Sort,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferenceID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.HiddenAt,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.ReplyCount,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
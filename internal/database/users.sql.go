@@ -12,6 +12,126 @@ import (
 	"github.com/google/uuid"
 )
 
+const banUser = `-- name: BanUser :one
+UPDATE users
+SET banned = TRUE, suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+func (q *Queries) BanUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, banUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const confirmEmail = `-- name: ConfirmEmail :one
+UPDATE users
+SET email = $1, pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $2 AND (email = $1 OR pending_email = $1)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+type ConfirmEmailParams struct {
+	Email sql.NullString
+	ID    uuid.UUID
+}
+
+func (q *Queries) ConfirmEmail(ctx context.Context, arg ConfirmEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const countAdmins = `-- name: CountAdmins :one
+SELECT COUNT(*) FROM users
+WHERE role = 'admin'
+`
+
+func (q *Queries) CountAdmins(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createExternalUser = `-- name: CreateExternalUser :one
+WITH new_user AS (
+    SELECT gen_random_uuid() AS id
+), identity AS (
+    INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+    SELECT gen_random_uuid(), new_user.id, $2, $3, $4, NOW(), NOW()
+    FROM new_user
+)
+INSERT INTO users (id, created_at, updated_at, email, email_verified_at)
+SELECT new_user.id, NOW(), NOW(), $1, NOW()
+FROM new_user
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+type CreateExternalUserParams struct {
+	Email         sql.NullString
+	Provider      string
+	Subject       string
+	IdentityEmail sql.NullString
+}
+
+// Users from identity providers have no password (hashed_password keeps its default),
+// and the provider has verified their email already. The identity is inserted in the same
+// statement, so no user is left without a way to log in when one of the inserts fails.
+func (q *Queries) CreateExternalUser(ctx context.Context, arg CreateExternalUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createExternalUser,
+		arg.Email,
+		arg.Provider,
+		arg.Subject,
+		arg.IdentityEmail,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red)
 VALUES (
@@ -22,7 +142,7 @@ VALUES (
     $2,
     FALSE
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
 `
 
 type CreateUserParams struct {
@@ -40,12 +160,43 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at FROM users
 WHERE email = $1
 `
 
@@ -59,6 +210,68 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (Use
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const liftUserSuspension = `-- name: LiftUserSuspension :one
+UPDATE users
+SET suspended_until = NULL, banned = FALSE, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+// suspended_at stays, so the tokens from before the suspension keep not working.
+func (q *Queries) LiftUserSuspension(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, liftUserSuspension, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const promoteFirstAdmin = `-- name: PromoteFirstAdmin :one
+UPDATE users
+SET role = 'admin', updated_at = NOW()
+WHERE users.email = $1 AND NOT EXISTS (SELECT 1 FROM users AS admins WHERE admins.role = 'admin')
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+// Does nothing once there is an admin, later admins are promoted by admins.
+func (q *Queries) PromoteFirstAdmin(ctx context.Context, email sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, promoteFirstAdmin, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
@@ -72,11 +285,43 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_until = $2, suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+type SuspendUserParams struct {
+	ID             uuid.UUID
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
 const updateChirpyRed = `-- name: UpdateChirpyRed :one
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
 `
 
 func (q *Queries) UpdateChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -89,25 +334,96 @@ func (q *Queries) UpdateChirpyRed(ctx context.Context, id uuid.UUID) (User, erro
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
-const updateEmailPassword = `-- name: UpdateEmailPassword :one
+const updatePassword = `-- name: UpdatePassword :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET hashed_password = $2, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
 `
 
-type UpdateEmailPasswordParams struct {
+type UpdatePasswordParams struct {
 	ID             uuid.UUID
-	Email          sql.NullString
 	HashedPassword string
 }
 
-func (q *Queries) UpdateEmailPassword(ctx context.Context, arg UpdateEmailPasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateEmailPassword, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updatePendingEmailPassword = `-- name: UpdatePendingEmailPassword :one
+UPDATE users
+SET pending_email = $2, hashed_password = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+type UpdatePendingEmailPasswordParams struct {
+	ID             uuid.UUID
+	PendingEmail   sql.NullString
+	HashedPassword string
+}
+
+// The email itself changes only when the pending email is verified.
+func (q *Queries) UpdatePendingEmailPassword(ctx context.Context, arg UpdatePendingEmailPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updatePendingEmailPassword, arg.ID, arg.PendingEmail, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, role, suspended_until, banned, suspended_at
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -116,6 +432,12 @@ func (q *Queries) UpdateEmailPassword(ctx context.Context, arg UpdateEmailPasswo
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.Banned,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,182 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Record is what is kept about the failed attempts of one key, like an account or an IP address.
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps the records, in memory for one server or in a database shared by several.
+type Store interface {
+	// Get returns an empty record for unknown keys.
+	Get(ctx context.Context, key string) (Record, error)
+	// Swap saves the new record only when the key still has the old one, and reports whether it did.
+	// Keys without a record have the empty one.
+	Swap(ctx context.Context, key string, old, new Record) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// Prune deletes the records with the last failure before the time.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Policy decides how long to wait after failures. The first FreeAttempts failures cost nothing,
+// then the delay doubles from BaseDelay up to MaxDelay with every failure, and after LockoutAfter
+// failures the key is locked for LockoutDuration. Failures are forgotten LockoutDuration after the last one.
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// Wait returns how long the key has to wait before the next attempt, and whether it is locked.
+func (p Policy) Wait(record Record, now time.Time) (time.Duration, bool) {
+	if record.Failures == 0 || now.Sub(record.LastFailure) >= p.LockoutDuration {
+		return 0, false
+	}
+
+	if p.LockoutAfter > 0 && record.Failures >= p.LockoutAfter {
+		return record.LastFailure.Add(p.LockoutDuration).Sub(now), true
+	}
+
+	if record.Failures <= p.FreeAttempts {
+		return 0, false
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < record.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	wait := record.LastFailure.Add(delay).Sub(now)
+	if wait < 0 {
+		return 0, false
+	}
+	return wait, false
+}
+
+// Limiter applies a policy to the keys of a store.
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// Attempt counts an attempt of the key as a failure before it is checked, so that parallel attempts
+// cannot all pass while the failures of the others are not counted yet. If the key has to wait,
+// the attempt is refused and not counted: Attempt returns how long to wait, and whether the key is locked.
+// A successful attempt is taken back with Release or Reset.
+func (l *Limiter) Attempt(ctx context.Context, key string) (time.Duration, bool, error) {
+	for {
+		record, err := l.store.Get(ctx, key)
+		if err != nil {
+			return 0, false, err
+		}
+		now := l.now()
+		wait, locked := l.policy.Wait(record, now)
+		if wait > 0 {
+			return wait, locked, nil
+		}
+
+		next := Record{Failures: record.Failures + 1, LastFailure: now}
+		if now.Sub(record.LastFailure) >= l.policy.LockoutDuration {
+			next.Failures = 1
+		}
+		swapped, err := l.store.Swap(ctx, key, record, next)
+		if err != nil {
+			return 0, false, err
+		}
+		if swapped {
+			return 0, false, nil
+		}
+	}
+}
+
+// Release takes back one attempt of the key that succeeded. The earlier failures stay.
+func (l *Limiter) Release(ctx context.Context, key string) error {
+	for {
+		record, err := l.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if record.Failures == 0 {
+			return nil
+		}
+
+		next := record
+		next.Failures--
+		swapped, err := l.store.Swap(ctx, key, record, next)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+	}
+}
+
+// Reset forgets the failures of the key, after a successful attempt or to unlock it.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, key)
+}
+
+// Prune deletes the records that the policy has forgotten already.
+func (l *Limiter) Prune(ctx context.Context) error {
+	return l.store.Prune(ctx, l.now().Add(-l.policy.LockoutDuration))
+}
+
+// MemoryStore keeps the records of one server.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Swap(ctx context.Context, key string, old, new Record) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[key]
+	if record.Failures != old.Failures || !record.LastFailure.Equal(old.LastFailure) {
+		return false, nil
+	}
+	s.records[key] = new
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, record := range s.records {
+		if record.LastFailure.Before(before) {
+			delete(s.records, key)
+		}
+	}
+	return nil
+}
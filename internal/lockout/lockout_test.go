@@ -0,0 +1,198 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+}
+
+func TestPolicyWait(t *testing.T) {
+	last := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		failures   int
+		since      time.Duration
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{
+			name:     "No failures",
+			failures: 0,
+			wantWait: 0,
+		},
+		{
+			name:     "Free attempts",
+			failures: 3,
+			wantWait: 0,
+		},
+		{
+			name:     "First delay",
+			failures: 4,
+			wantWait: time.Second,
+		},
+		{
+			name:     "Delay doubles",
+			failures: 6,
+			wantWait: 4 * time.Second,
+		},
+		{
+			name:     "Delay partly waited",
+			failures: 6,
+			since:    3 * time.Second,
+			wantWait: time.Second,
+		},
+		{
+			name:     "Delay waited",
+			failures: 6,
+			since:    5 * time.Second,
+			wantWait: 0,
+		},
+		{
+			name:     "Delay is capped",
+			failures: 9,
+			wantWait: 32 * time.Second,
+		},
+		{
+			name:       "Locked",
+			failures:   10,
+			since:      time.Minute,
+			wantWait:   14 * time.Minute,
+			wantLocked: true,
+		},
+		{
+			name:     "Lockout is over",
+			failures: 12,
+			since:    15 * time.Minute,
+			wantWait: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wait, locked := testPolicy.Wait(Record{Failures: test.failures, LastFailure: last}, last.Add(test.since))
+			if wait != test.wantWait || locked != test.wantLocked {
+				t.Errorf("Wait() = %s, %v, want %s, %v", wait, locked, test.wantWait, test.wantLocked)
+			}
+		})
+	}
+}
+
+func TestPolicyWaitMaxDelay(t *testing.T) {
+	policy := testPolicy
+	policy.LockoutAfter = 0
+	last := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	wait, locked := policy.Wait(Record{Failures: 200, LastFailure: last}, last)
+	if wait != policy.MaxDelay || locked {
+		t.Errorf("Wait() = %s, %v, want %s, false", wait, locked, policy.MaxDelay)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+	limiter.now = func() time.Time { return now }
+
+	// Every attempt waits out the delay of the one before, so the limiter counts each of them.
+	for i := 0; i < testPolicy.LockoutAfter; i++ {
+		wait, _, err := limiter.Attempt(ctx, "account")
+		if err != nil || wait != 0 {
+			t.Fatalf("Attempt() %d = %s, %v, want no wait", i+1, wait, err)
+		}
+		now = now.Add(testPolicy.MaxDelay)
+	}
+
+	wait, locked, err := limiter.Attempt(ctx, "account")
+	if err != nil || !locked {
+		t.Errorf("Attempt() after %d failures = %s, %v, %v, want locked", testPolicy.LockoutAfter, wait, locked, err)
+	}
+
+	wait, locked, _ = limiter.Attempt(ctx, "other")
+	if wait != 0 || locked {
+		t.Errorf("Attempt() of another key = %s, %v, want no wait", wait, locked)
+	}
+
+	now = now.Add(testPolicy.LockoutDuration)
+	limiter.Attempt(ctx, "account")
+	wait, locked, _ = limiter.Attempt(ctx, "account")
+	if wait != 0 || locked {
+		t.Errorf("Attempt() after the failures are forgotten = %s, %v, want no wait", wait, locked)
+	}
+
+	limiter.Reset(ctx, "account")
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		limiter.Attempt(ctx, "account")
+	}
+	limiter.Release(ctx, "account")
+	for i := 0; i < 2; i++ {
+		wait, locked, _ = limiter.Attempt(ctx, "account")
+		if wait != 0 || locked {
+			t.Errorf("Attempt() %d after Release() = %s, %v, want no wait", i+1, wait, locked)
+		}
+	}
+	wait, _, _ = limiter.Attempt(ctx, "account")
+	if wait != testPolicy.BaseDelay {
+		t.Errorf("Attempt() after the free attempts = %s, want %s", wait, testPolicy.BaseDelay)
+	}
+}
+
+func TestLimiterParallelAttempts(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, _, err := limiter.Attempt(ctx, "account")
+			if err != nil {
+				t.Errorf("Attempt() error = %v", err)
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The delay after the free attempts starts with the failure that ends them.
+	if want := testPolicy.FreeAttempts + 1; allowed != want {
+		t.Errorf("Parallel attempts allowed = %d, want %d", allowed, want)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	store := NewMemoryStore()
+	store.Swap(ctx, "old", Record{}, Record{Failures: 1, LastFailure: now.Add(-time.Hour)})
+	store.Swap(ctx, "new", Record{}, Record{Failures: 1, LastFailure: now})
+
+	err := store.Prune(ctx, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if record, _ := store.Get(ctx, "old"); record.Failures != 0 {
+		t.Errorf("Old record was not pruned: %+v", record)
+	}
+	if record, _ := store.Get(ctx, "new"); record.Failures != 1 {
+		t.Errorf("New record was pruned: %+v", record)
+	}
+}
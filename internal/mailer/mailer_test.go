@@ -0,0 +1,192 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMessage(t *testing.T) {
+	now := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      Message
+		wantPart string
+		wantErr  bool
+	}{
+		{
+			name:     "Plain message",
+			msg:      Message{To: "user@example.com", Subject: "Hello", Body: "Line one\nLine two"},
+			wantPart: "To: user@example.com\r\nSubject: Hello\r\nDate: Fri, 14 Mar 2025 15:09:26 +0000\r\n",
+		},
+		{
+			name:     "Body lines end with CRLF",
+			msg:      Message{To: "user@example.com", Subject: "Hello", Body: "Line one\nLine two"},
+			wantPart: "\r\n\r\nLine one\r\nLine two\r\n",
+		},
+		{
+			name:     "Subject with non-ASCII letters",
+			msg:      Message{To: "user@example.com", Subject: "Привет"},
+			wantPart: "Subject: =?utf-8?q?",
+		},
+		{
+			name:    "Header injection",
+			msg:     Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hello"},
+			wantErr: true,
+		},
+		{
+			name:    "No recipient",
+			msg:     Message{Subject: "Hello"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := formatMessage("Chirpy <no-reply@example.com>", test.msg, now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("formatMessage() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !strings.Contains(string(got), test.wantPart) {
+				t.Errorf("formatMessage() = %q, want it to contain %q", got, test.wantPart)
+			}
+		})
+	}
+}
+
+func TestLogSend(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLog(&buf, "Chirpy <no-reply@example.com>")
+
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Reset", Body: "token-123"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "To: user@example.com") || !strings.Contains(buf.String(), "token-123") {
+		t.Errorf("Send() wrote %q", buf.String())
+	}
+}
+
+func TestNewSMTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		from    string
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			addr: "smtp.example.com:587",
+			from: "Chirpy <no-reply@example.com>",
+		},
+		{
+			name:    "No port",
+			addr:    "smtp.example.com",
+			from:    "no-reply@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "No sender",
+			addr:    "smtp.example.com:587",
+			from:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewSMTP(test.addr, "", "", test.from)
+			if (err != nil) != test.wantErr {
+				t.Errorf("NewSMTP() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	m, err := NewSMTP(listener.Addr().String(), "", "", "Chirpy <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, Message{To: "user@example.com", Subject: "Reset", Body: ".hidden\ntoken-123"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	commands := <-received
+	want := []string{"MAIL FROM:<no-reply@example.com>", "RCPT TO:<user@example.com>"}
+	for _, command := range want {
+		if !contains(commands, command) {
+			t.Errorf("Server got %q, want %q", commands, command)
+		}
+	}
+	if !contains(commands, ".hidden") || !contains(commands, "token-123") {
+		t.Errorf("Server got %q, want the body", commands)
+	}
+}
+
+// serveSMTP answers one client like a server without extensions and sends back
+// the commands and the data lines it got.
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	lines := []string{}
+	text.PrintfLine("220 localhost ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			text.PrintfLine("250 localhost")
+		case line == "DATA":
+			text.PrintfLine("354 go ahead")
+			body, err := text.ReadDotLines()
+			if err != nil {
+				received <- lines
+				return
+			}
+			lines = append(lines, body...)
+			text.PrintfLine("250 queued")
+		case line == "QUIT":
+			text.PrintfLine("221 bye")
+			received <- lines
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+	received <- lines
+}
+
+func contains(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+	server, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(Config{
+		Issuer:       server.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://chirpy.test/api/oidc/test/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	return provider, server
+}
+
+func TestProviderLogin(t *testing.T) {
+	provider, server := newTestProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	tests := []struct {
+		name          string
+		tokenNonce    string
+		wrongVerifier bool
+		wantErr       bool
+	}{
+		{
+			name: "Valid login",
+		},
+		{
+			name:          "Wrong code verifier",
+			wrongVerifier: true,
+			wantErr:       true,
+		},
+		{
+			name:       "Replayed ID token with another nonce",
+			tokenNonce: "other-nonce",
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server.Nonce = test.tokenNonce
+			verifier, _ := GenerateVerifier()
+			authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL() error = %v", err)
+			}
+
+			callback, err := server.Authorize(authURL)
+			if err != nil {
+				t.Fatalf("Authorize() error = %v", err)
+			}
+			if callback.Query().Get("state") != "state-1" {
+				t.Errorf("Callback state = %q, want %q", callback.Query().Get("state"), "state-1")
+			}
+
+			if test.wrongVerifier {
+				verifier, _ = GenerateVerifier()
+			}
+			identity, err := provider.Login(context.Background(), callback.Query().Get("code"), verifier, "nonce-1")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Login() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && (identity.Subject != "user-1" || identity.Email != "user@example.com" || !identity.EmailVerified) {
+				t.Errorf("Login() = %+v, want user-1", identity)
+			}
+		})
+	}
+}
+
+func TestProviderLoginCodeOnlyOnce(t *testing.T) {
+	provider, server := newTestProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1"})
+
+	verifier, _ := GenerateVerifier()
+	authURL, _ := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	callback, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	code := callback.Query().Get("code")
+
+	_, err = provider.Login(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	_, err = provider.Login(context.Background(), code, verifier, "nonce")
+	if err == nil {
+		t.Errorf("Login() with a used code error = nil, want error")
+	}
+}
+
+func TestProviderVerifyRejectsOtherProvider(t *testing.T) {
+	provider, _ := newTestProvider(t)
+	other, otherServer := newTestProvider(t)
+	otherServer.SetIdentity(oidctest.Identity{Subject: "user-1"})
+
+	verifier, _ := GenerateVerifier()
+	authURL, _ := other.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	callback, err := otherServer.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	idToken, err := other.exchange(context.Background(), callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("exchange() error = %v", err)
+	}
+
+	_, err = provider.Verify(context.Background(), idToken, "nonce")
+	if err == nil {
+		t.Errorf("Verify() with a token of another provider error = nil, want error")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// https://www.rfc-editor.org/rfc/rfc7636#appendix-B
+	got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got != want {
+		t.Errorf("Challenge() = %s, want %s", got, want)
+	}
+}
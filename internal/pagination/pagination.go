@@ -0,0 +1,91 @@
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor points at the last row of a page, so the next page starts right after it.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func EncodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(cursor string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, errors.New("Cursor is not valid")
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 {
+		return Cursor{}, errors.New("Cursor is not valid")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, errors.New("Cursor is not valid")
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Cursor{}, errors.New("Cursor is not valid")
+	}
+
+	return Cursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// ParseCursor turns the optional "cursor" query parameter into the nullable arguments of the page queries.
+func ParseCursor(cursor string) (sql.NullTime, uuid.NullUUID, error) {
+	if cursor == "" {
+		return sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		return sql.NullTime{}, uuid.NullUUID{}, err
+	}
+	return sql.NullTime{Time: decoded.CreatedAt, Valid: true}, uuid.NullUUID{UUID: decoded.ID, Valid: true}, nil
+}
+
+// ParseLimit reads the "limit" query parameter, falling back to DefaultLimit when it is empty.
+func ParseLimit(limit string) (int32, error) {
+	if limit == "" {
+		return DefaultLimit, nil
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > MaxLimit {
+		return 0, errors.New("Limit must be a number between 1 and " + strconv.Itoa(MaxLimit))
+	}
+	return int32(n), nil
+}
+
+// ParseOffset reads the "offset" query parameter used by endpoints that are not ordered by time.
+func ParseOffset(offset string) (int32, error) {
+	if offset == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 0 {
+		return 0, errors.New("Offset must be a non-negative number")
+	}
+	return int32(n), nil
+}
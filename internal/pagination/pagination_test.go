@@ -0,0 +1,153 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEncodeAndDecodeCursor(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	id := uuid.New()
+
+	cursor, err := DecodeCursor(EncodeCursor(createdAt, id))
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !cursor.CreatedAt.Equal(createdAt) || cursor.ID != id {
+		t.Errorf("DecodeCursor() = %v, want %v %v", cursor, createdAt, id)
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{
+			name:   "Not base64",
+			cursor: "%%%",
+		},
+		{
+			name:   "Missing separator",
+			cursor: "bm9zZXBhcmF0b3I",
+		},
+		{
+			name:   "Invalid time",
+			cursor: base64.RawURLEncoding.EncodeToString([]byte("yesterday|" + uuid.NewString())),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeCursor(test.cursor)
+			if err == nil {
+				t.Errorf("DecodeCursor() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   string
+		want    int32
+		wantErr bool
+	}{
+		{
+			name:  "Empty limit",
+			limit: "",
+			want:  DefaultLimit,
+		},
+		{
+			name:  "Valid limit",
+			limit: "50",
+			want:  50,
+		},
+		{
+			name:    "Zero limit",
+			limit:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "Too big limit",
+			limit:   "1000",
+			wantErr: true,
+		},
+		{
+			name:    "Not a number",
+			limit:   "ten",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseLimit(test.limit)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseLimit() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("ParseLimit() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  string
+		want    int32
+		wantErr bool
+	}{
+		{
+			name:   "Empty offset",
+			offset: "",
+			want:   0,
+		},
+		{
+			name:   "Valid offset",
+			offset: "40",
+			want:   40,
+		},
+		{
+			name:    "Negative offset",
+			offset:  "-1",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseOffset(test.offset)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseOffset() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("ParseOffset() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	createdAt, id, err := ParseCursor("")
+	if err != nil || createdAt.Valid || id.Valid {
+		t.Errorf("ParseCursor(\"\") = %v, %v, %v, want empty values", createdAt, id, err)
+	}
+
+	want := uuid.New()
+	createdAt, id, err = ParseCursor(EncodeCursor(time.Now(), want))
+	if err != nil || !createdAt.Valid || !id.Valid || id.UUID != want {
+		t.Errorf("ParseCursor() = %v, %v, %v, want cursor for %v", createdAt, id, err, want)
+	}
+
+	_, _, err = ParseCursor("%%%")
+	if err == nil {
+		t.Errorf("ParseCursor() error = nil, want error")
+	}
+}
@@ -0,0 +1,95 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KeySize = 32
+	prefix  = "v1:"
+)
+
+// Box encrypts small secrets for the database with AES-256-GCM. A sealed secret
+// is "v1:" and the base64 of the nonce and the ciphertext.
+// It also hashes values for lookups with HMAC-SHA256, under a key derived from the same key.
+type Box struct {
+	aead      cipher.AEAD
+	digestKey []byte
+}
+
+// ParseKey reads a key made with e.g. `openssl rand -base64 32`.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("Key is not base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Key must be %d bytes, not %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Key must be %d bytes, not %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	digestKey, err := hkdf.Key(sha256.New, key, nil, "secretbox digest", sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead, digestKey: digestKey}, nil
+}
+
+// Seal encrypts the plaintext bound to the additional data, e.g. the ID of the row owner,
+// so that the sealed secret does not open when it is copied to another row.
+func (b *Box) Seal(plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, additionalData)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed secret with the same additional data as it was sealed with.
+func (b *Box) Open(sealed string, additionalData []byte) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(sealed, prefix)
+	if !ok {
+		return nil, errors.New("Unknown format of the sealed secret")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < b.aead.NonceSize() {
+		return nil, errors.New("Sealed secret is too short")
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	return b.aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Digest returns the keyed hash of a random value, like a recovery code, which can be looked up
+// in the database. It is fast, so it must not be used for passwords that people choose.
+func (b *Box) Digest(value []byte) string {
+	mac := hmac.New(sha256.New, b.digestKey)
+	mac.Write(value)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,149 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	otherBox, _ := New(bytes.Repeat([]byte{8}, KeySize))
+
+	secret := []byte("12345678901234567890")
+	owner := []byte("c0ffee00-0000-4000-8000-000000000001")
+	sealed, err := box.Seal(secret, owner)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if strings.Contains(sealed, string(secret)) {
+		t.Errorf("Seal() = %s contains the secret", sealed)
+	}
+	again, _ := box.Seal(secret, owner)
+	if again == sealed {
+		t.Errorf("Seal() twice gave the same result, want a new nonce")
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-2] ^= 1
+
+	tests := []struct {
+		name           string
+		box            *Box
+		sealed         string
+		additionalData []byte
+		wantErr        bool
+	}{
+		{
+			name:           "Same key",
+			box:            box,
+			sealed:         sealed,
+			additionalData: owner,
+		},
+		{
+			name:           "Other key",
+			box:            otherBox,
+			sealed:         sealed,
+			additionalData: owner,
+			wantErr:        true,
+		},
+		{
+			name:           "Other owner",
+			box:            box,
+			sealed:         sealed,
+			additionalData: []byte("c0ffee00-0000-4000-8000-000000000002"),
+			wantErr:        true,
+		},
+		{
+			name:    "No additional data",
+			box:     box,
+			sealed:  sealed,
+			wantErr: true,
+		},
+		{
+			name:           "Tampered",
+			box:            box,
+			sealed:         string(tampered),
+			additionalData: owner,
+			wantErr:        true,
+		},
+		{
+			name:           "Plain text",
+			box:            box,
+			sealed:         string(secret),
+			additionalData: owner,
+			wantErr:        true,
+		},
+		{
+			name:           "Too short",
+			box:            box,
+			sealed:         "v1:AAAA",
+			additionalData: owner,
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.box.Open(test.sealed, test.additionalData)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !bytes.Equal(got, secret) {
+				t.Errorf("Open() = %q, want %q", got, secret)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	box, _ := New(bytes.Repeat([]byte{7}, KeySize))
+	otherBox, _ := New(bytes.Repeat([]byte{8}, KeySize))
+
+	digest := box.Digest([]byte("k3jd9x2mfq"))
+	if digest != box.Digest([]byte("k3jd9x2mfq")) {
+		t.Errorf("Digest() of the same value differs")
+	}
+	if digest == box.Digest([]byte("k3jd9x2mfr")) {
+		t.Errorf("Digest() of another value is the same")
+	}
+	if digest == otherBox.Digest([]byte("k3jd9x2mfq")) {
+		t.Errorf("Digest() with another key is the same")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{
+			name: "32 bytes",
+			key:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)) + "\n",
+		},
+		{
+			name:    "16 bytes",
+			key:     base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)),
+			wantErr: true,
+		},
+		{
+			name:    "Not base64",
+			key:     "not a key!",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseKey(test.key)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseKey() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors of https://www.rfc-editor.org/rfc/rfc6238#appendix-B.
+func TestCode(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	tests := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{
+			name: "1970",
+			time: 59,
+			want: "94287082",
+		},
+		{
+			name: "2005",
+			time: 1111111109,
+			want: "07081804",
+		},
+		{
+			name: "2005 next step",
+			time: 1111111111,
+			want: "14050471",
+		},
+		{
+			name: "2009",
+			time: 1234567890,
+			want: "89005924",
+		},
+		{
+			name: "2033",
+			time: 2000000000,
+			want: "69279037",
+		},
+		{
+			name: "2603",
+			time: 20000000000,
+			want: "65353130",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Code(secret, Step(time.Unix(test.time, 0)), 8)
+			if got != test.want {
+				t.Errorf("Code() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	now := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	step := Step(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{
+			name:     "Current code",
+			code:     Code(secret, step, Digits),
+			wantStep: step,
+			wantOK:   true,
+		},
+		{
+			name:     "Code with a space",
+			code:     Code(secret, step, Digits)[:3] + " " + Code(secret, step, Digits)[3:],
+			wantStep: step,
+			wantOK:   true,
+		},
+		{
+			name:     "Previous code",
+			code:     Code(secret, step-1, Digits),
+			wantStep: step - 1,
+			wantOK:   true,
+		},
+		{
+			name:     "Next code",
+			code:     Code(secret, step+1, Digits),
+			wantStep: step + 1,
+			wantOK:   true,
+		},
+		{
+			name:   "Old code",
+			code:   Code(secret, step-2, Digits),
+			wantOK: false,
+		},
+		{
+			name:   "Wrong length",
+			code:   "12345",
+			wantOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotStep, ok := Validate(secret, test.code, now)
+			if ok != test.wantOK || gotStep != test.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", gotStep, ok, test.wantStep, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Chirpy", "user@example.com", []byte("12345678901234567890"))
+	want := []string{
+		"otpauth://totp/Chirpy:user@example.com?",
+		"secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"issuer=Chirpy",
+		"digits=6",
+		"period=30",
+	}
+	for _, part := range want {
+		if !strings.Contains(uri, part) {
+			t.Errorf("ProvisioningURI() = %s, want it to contain %s", uri, part)
+		}
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode() error = %v", err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Errorf("GenerateRecoveryCode() = %q, want xxxxx-xxxxx", code)
+	}
+	other, _ := GenerateRecoveryCode()
+	if other == code {
+		t.Errorf("GenerateRecoveryCode() twice = %q", code)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "As given",
+			input: code,
+		},
+		{
+			name:  "Upper case",
+			input: strings.ToUpper(code),
+		},
+		{
+			name:  "Without the dash",
+			input: strings.ReplaceAll(code, "-", ""),
+		},
+		{
+			name:  "With spaces",
+			input: " " + strings.ReplaceAll(code, "-", " ") + " ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, want := NormalizeRecoveryCode(test.input), NormalizeRecoveryCode(code); got != want {
+				t.Errorf("NormalizeRecoveryCode() = %q, want %q", got, want)
+			}
+		})
+	}
+}
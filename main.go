@@ -1,32 +1,83 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/contentfilter"
 	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/lockout"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/mailer"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/oidc"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/secretbox"
 	"github.com/joho/godotenv"
 )
 
 import _ "github.com/lib/pq"
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platformAPI    string
-	keyJWT         string
-	keyPolka       string
+	fileserverHits        atomic.Int32
+	dbQueries             store
+	mailer                mailer.Mailer
+	passwordResetURL      string
+	emailVerificationURL  string
+	unverifiedDailyChirps int
+	loginAccounts         *lockout.Limiter
+	loginIPs              *lockout.Limiter
+	totpBox               *secretbox.Box
+	passwordHasher        *auth.PasswordHasher
+	passwordPolicy        *auth.PasswordPolicy
+	oidcProviders         map[string]*oidc.Provider
+	platformAPI           string
+	jwtKeys               *auth.KeySet
+	keyPolka              string
+	contentFilter         *contentfilter.Filter
+	maxChirpLength        int
+	maxChirpLengthRed     int
+	chirpEditWindow       time.Duration
+	chirpRestorePeriod    time.Duration
+	chirpRetention        time.Duration
 }
 
 func main() {
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
-	key := os.Getenv("KEY_JWT")
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		keysDir = "keys"
+	}
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	keyRotation := envDuration("JWT_KEY_ROTATION", 30*24*time.Hour)
 	polka := os.Getenv("POLKA_KEY")
+	passwordResetURL := os.Getenv("PASSWORD_RESET_URL")
+	emailVerificationURL := os.Getenv("EMAIL_VERIFICATION_URL")
+	unverifiedDailyChirps := envCount("UNVERIFIED_DAILY_CHIRPS", 0)
+	loginAttemptsStore := os.Getenv("LOGIN_ATTEMPTS_STORE")
+	loginLockoutAttempts := envInt("LOGIN_LOCKOUT_ATTEMPTS", 10)
+	loginIPLockoutAttempts := envInt("LOGIN_IP_LOCKOUT_ATTEMPTS", 100)
+	loginLockoutDuration := envDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	totpKey := os.Getenv("TOTP_ENCRYPTION_KEY")
+	passwordMinLength := envInt("PASSWORD_MIN_LENGTH", 8)
+	passwordMaxLength := envInt("PASSWORD_MAX_LENGTH", 128)
+	breachedPasswordsFile := os.Getenv("BREACHED_PASSWORDS_FILE")
+	argonMemory := envInt("ARGON2_MEMORY", 64*1024)
+	argonIterations := envInt("ARGON2_ITERATIONS", 1)
+	argonParallelism := envInt("ARGON2_PARALLELISM", 2)
+	filterFile := os.Getenv("CONTENT_FILTER_FILE")
+	maxLength := envInt("CHIRP_MAX_LENGTH", 140)
+	maxRedLength := envInt("CHIRP_MAX_LENGTH_RED", 280)
+	editWindow := envDuration("CHIRP_EDIT_WINDOW", 15*time.Minute)
+	restorePeriod := envDuration("CHIRP_RESTORE_PERIOD", 24*time.Hour)
+	retention := envDuration("CHIRP_RETENTION", 30*24*time.Hour)
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -34,37 +85,216 @@ func main() {
 	}
 	dbQueriesNew := database.New(db)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bootstrap-admin":
+			err = bootstrapAdmin(context.Background(), dbQueriesNew, strings.Join(os.Args[2:], " "))
+		default:
+			err = fmt.Errorf("Unknown command %q, the only command is bootstrap-admin", os.Args[1])
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	filter, err := loadContentFilter(dbQueriesNew, filterFile)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	jwtKeys, err := auth.LoadKeySet(keysDir, algorithm, keyRotation, keyPublishDelay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	mail, err := loadMailer()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	loginAccounts, loginIPs, err := loadLoginLimiters(dbQueriesNew, loginAttemptsStore, loginLockoutAttempts, loginIPLockoutAttempts, loginLockoutDuration)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var totpBox *secretbox.Box
+	if totpKey == "" {
+		fmt.Println("TOTP_ENCRYPTION_KEY is not set, two-factor authentication is not available")
+	} else {
+		key, err := secretbox.ParseKey(totpKey)
+		if err == nil {
+			totpBox, err = secretbox.New(key)
+		}
+		if err != nil {
+			fmt.Println("TOTP_ENCRYPTION_KEY:", err)
+			os.Exit(1)
+		}
+	}
+
+	passwordHasher, err := auth.NewPasswordHasher(uint32(argonMemory), uint32(argonIterations), uint8(min(argonParallelism, 255)))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	passwordPolicy, err := auth.LoadPasswordPolicy(passwordMinLength, passwordMaxLength, breachedPasswordsFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	var counter atomic.Int32
 	counter.Store(0)
 	apiCfg := apiConfig{
-		fileserverHits: counter,
-		dbQueries:      dbQueriesNew,
-		platformAPI:    platform,
-		keyJWT:         key,
-		keyPolka:       polka,
+		fileserverHits:        counter,
+		dbQueries:             dbQueriesNew,
+		mailer:                mail,
+		passwordResetURL:      passwordResetURL,
+		emailVerificationURL:  emailVerificationURL,
+		unverifiedDailyChirps: unverifiedDailyChirps,
+		loginAccounts:         loginAccounts,
+		loginIPs:              loginIPs,
+		totpBox:               totpBox,
+		passwordHasher:        passwordHasher,
+		passwordPolicy:        passwordPolicy,
+		oidcProviders:         oidcProviders,
+		platformAPI:           platform,
+		jwtKeys:               jwtKeys,
+		keyPolka:              polka,
+		contentFilter:         filter,
+		maxChirpLength:        maxLength,
+		maxChirpLengthRed:     maxRedLength,
+		chirpEditWindow:       editWindow,
+		chirpRestorePeriod:    restorePeriod,
+		chirpRetention:        retention,
 	}
 	serveMux := http.NewServeMux()
 	serveMux.HandleFunc("GET /api/healthz", handlerFunc)
-	serveMux.HandleFunc("GET /admin/metrics", apiCfg.handlerNRequests)
-	serveMux.HandleFunc("POST /admin/reset", apiCfg.handlerResetRequests)
+	serveMux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	serveMux.HandleFunc("GET /admin/metrics", apiCfg.middlewareRole(auth.RoleAdmin, apiCfg.handlerNRequests))
+	serveMux.HandleFunc("POST /admin/reset", apiCfg.middlewareRole(auth.RoleAdmin, apiCfg.handlerResetRequests))
+	serveMux.HandleFunc("POST /admin/users/{userID}/unlock", apiCfg.middlewareRole(auth.RoleAdmin, apiCfg.handlerUnlockUser))
+	serveMux.HandleFunc("PUT /admin/users/{userID}/role", apiCfg.middlewareRole(auth.RoleAdmin, apiCfg.handlerSetUserRole))
+	serveMux.HandleFunc("GET /admin/filter/words", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerGetFilteredWords))
+	serveMux.HandleFunc("PUT /admin/filter/words/{word}", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerSetFilteredWord))
+	serveMux.HandleFunc("DELETE /admin/filter/words/{word}", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerDeleteFilteredWord))
+	serveMux.HandleFunc("GET /admin/flagged-chirps", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerGetFlaggedChirps))
+	serveMux.HandleFunc("DELETE /admin/flagged-chirps/{chirpID}", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerDeleteFlaggedChirp))
+	serveMux.HandleFunc("PUT /admin/users/{userID}/suspension", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerSuspendUser))
+	serveMux.HandleFunc("DELETE /admin/users/{userID}/suspension", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerLiftSuspension))
+	serveMux.HandleFunc("GET /admin/reports", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerGetReportQueue))
+	serveMux.HandleFunc("PUT /admin/reports/{reportID}/assignee", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerAssignReport))
+	serveMux.HandleFunc("POST /admin/reports/{reportID}/actions", apiCfg.middlewareRole(auth.RoleModerator, apiCfg.handlerModerateReport))
 
-	serveMux.HandleFunc("POST /api/chirps", apiCfg.handlerChirps)
-	serveMux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
-	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	serveMux.HandleFunc("POST /api/chirps", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerChirps))
+	serveMux.HandleFunc("GET /api/chirps", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerGetChirps))
+	serveMux.HandleFunc("GET /api/chirps/search", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerSearchChirps))
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerGetChirp))
+	serveMux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerEditChirp))
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerDeleteChirp))
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/history", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerGetChirpHistory))
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerRestoreChirp))
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerLikeChirp))
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerUnlikeChirp))
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerGetThread))
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerRechirp))
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/reports", apiCfg.middlewareScope(auth.ScopeChirpsWrite, apiCfg.handlerReportChirp))
 
 	serveMux.HandleFunc("POST /api/users", apiCfg.handlerNewUser)
+	serveMux.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	serveMux.HandleFunc("POST /api/users/verification", apiCfg.middlewareAuth(apiCfg.handlerResendVerification))
 	serveMux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	serveMux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
 	serveMux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	serveMux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	serveMux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	serveMux.HandleFunc("POST /api/password-reset", apiCfg.handlerPasswordReset)
+	serveMux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerPasswordResetConfirm)
+	serveMux.HandleFunc("GET /api/oidc/{provider}/login", apiCfg.handlerOIDCLogin)
+	serveMux.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.handlerOIDCCallback)
+	serveMux.HandleFunc("POST /api/oidc/{provider}/link", apiCfg.middlewareAuth(apiCfg.handlerOIDCLink))
+	serveMux.HandleFunc("GET /api/users/identities", apiCfg.middlewareAuth(apiCfg.handlerGetIdentities))
+	serveMux.HandleFunc("DELETE /api/users/identities/{provider}", apiCfg.middlewareAuth(apiCfg.handlerUnlinkIdentity))
+	serveMux.HandleFunc("POST /api/tokens", apiCfg.middlewareAuth(apiCfg.handlerCreateAPIToken))
+	serveMux.HandleFunc("GET /api/tokens", apiCfg.middlewareAuth(apiCfg.handlerGetAPITokens))
+	serveMux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.middlewareAuth(apiCfg.handlerRevokeAPIToken))
+	serveMux.HandleFunc("GET /api/sessions", apiCfg.middlewareAuth(apiCfg.handlerGetSessions))
+	serveMux.HandleFunc("DELETE /api/sessions", apiCfg.middlewareAuth(apiCfg.handlerRevokeSessions))
+	serveMux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.middlewareAuth(apiCfg.handlerRevokeSession))
+	serveMux.HandleFunc("POST /api/users/2fa", apiCfg.middlewareAuth(apiCfg.handlerEnrollTwoFactor))
+	serveMux.HandleFunc("POST /api/users/2fa/confirm", apiCfg.middlewareAuth(apiCfg.handlerConfirmTwoFactor))
+	serveMux.HandleFunc("DELETE /api/users/2fa", apiCfg.middlewareAuth(apiCfg.handlerDisableTwoFactor))
+	serveMux.HandleFunc("PUT /api/users", apiCfg.middlewareAuth(apiCfg.handlerUpdateUser))
 	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerChirpyRed)
 
+	serveMux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.middlewareScope(auth.ScopeFollowsWrite, apiCfg.handlerFollow))
+	serveMux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.middlewareScope(auth.ScopeFollowsWrite, apiCfg.handlerUnfollow))
+	serveMux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerGetFollowers)
+	serveMux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerGetFollowing)
+	serveMux.HandleFunc("GET /api/timeline", apiCfg.middlewareScope(auth.ScopeChirpsRead, apiCfg.handlerTimeline))
+
 	serveMux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
 	serverStruct := http.Server{
 		Addr:    ":8080",
-		Handler: serveMux,
+		Handler: middlewareRequestID(serveMux),
 	}
+	go apiCfg.purgeDeletedChirps(context.Background())
+	go apiCfg.rotateJWTKeys(context.Background())
+	go apiCfg.pruneLoginAttempts(context.Background())
+
 	err = serverStruct.ListenAndServe()
 	fmt.Println(err)
 }
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		fmt.Printf("%s must be a positive number, using %d\n", name, fallback)
+		return fallback
+	}
+	return n
+}
+
+// envCount is like envInt, but zero is allowed.
+func envCount(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		fmt.Printf("%s must be zero or a positive number, using %d\n", name, fallback)
+		return fallback
+	}
+	return n
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		fmt.Printf("%s must be a duration like 15m, using %s\n", name, fallback)
+		return fallback
+	}
+	return d
+}
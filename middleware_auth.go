@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type authContextKey struct{}
+
+// authInfo is what the auth middlewares put into the request context.
+type authInfo struct {
+	UserID uuid.UUID
+	// Claims are nil for personal API tokens.
+	Claims *auth.Claims
+	// Scopes are the scopes of a personal API token; access tokens are allowed everything.
+	Scopes []string
+	// Role is the role the user has now, so a role change works at once. It is empty for
+	// personal API tokens, which cannot use the roles of their user.
+	Role string
+}
+
+// middlewareAuth lets the request through only with a valid access token.
+// Personal API tokens are refused, since the route has no scope for them.
+func (cfg *apiConfig) middlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareScope("", next)
+}
+
+// middlewareScope is like middlewareAuth, but also accepts personal API tokens with the scope.
+func (cfg *apiConfig) middlewareScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		info, ok := cfg.authenticate(resp, req, scope)
+		if !ok {
+			return
+		}
+		next(resp, req.WithContext(context.WithValue(req.Context(), authContextKey{}, info)))
+	}
+}
+
+// middlewareOptionalAuth lets anonymous requests through too, but a token that was sent has to be valid,
+// and personal API tokens need the scope.
+func (cfg *apiConfig) middlewareOptionalAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "" {
+			next(resp, req)
+			return
+		}
+
+		info, ok := cfg.authenticate(resp, req, scope)
+		if !ok {
+			return
+		}
+		next(resp, req.WithContext(context.WithValue(req.Context(), authContextKey{}, info)))
+	}
+}
+
+// middlewareRole lets the request through only with an access token of a user with the role or a higher one.
+func (cfg *apiConfig) middlewareRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		info, ok := cfg.authenticate(resp, req, "")
+		if !ok {
+			return
+		}
+
+		if !auth.HasRole(info.Role, role) {
+			type details struct {
+				RequiredRole string `json:"required_role"`
+			}
+			responseErrorDetails(resp, req, 403, errCodeForbidden, fmt.Sprintf("This endpoint requires the %s role", role), details{RequiredRole: role})
+			return
+		}
+		next(resp, req.WithContext(context.WithValue(req.Context(), authContextKey{}, info)))
+	}
+}
+
+func (cfg *apiConfig) authenticate(resp http.ResponseWriter, req *http.Request, scope string) (authInfo, bool) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		log.Printf("Error getting Bearer token: %s", err)
+		responseUnauthorized(resp, req)
+		return authInfo{}, false
+	}
+
+	if auth.IsAPIToken(token) {
+		return cfg.authenticateAPIToken(resp, req, token, scope)
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtKeys)
+	if err != nil {
+		log.Printf("Error validating JWT: %s", err)
+		responseUnauthorized(resp, req)
+		return authInfo{}, false
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		log.Printf("Error parsing JWT subject to UUID: %s", err)
+		responseUnauthorized(resp, req)
+		return authInfo{}, false
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	user, ok := cfg.checkTokenUser(resp, req, userID, issuedAt)
+	if !ok {
+		return authInfo{}, false
+	}
+
+	return authInfo{UserID: userID, Claims: claims, Role: user.Role}, true
+}
+
+// checkTokenUser refuses the tokens of banned and suspended users, and the tokens issued before
+// the user was last suspended, and returns the user of the token. It costs a query on every request,
+// but suspensions and role changes work at once instead of when the access token expires.
+func (cfg *apiConfig) checkTokenUser(resp http.ResponseWriter, req *http.Request, userID uuid.UUID, issuedAt time.Time) (database.User, bool) {
+	user, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("User %s of the token doesn't exist", userID)
+		responseUnauthorized(resp, req)
+		return database.User{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting user: %s", err)
+		responseInternalError(resp, req)
+		return database.User{}, false
+	}
+
+	if !checkNotSuspended(resp, req, user) {
+		return database.User{}, false
+	}
+	if issuedBeforeSuspension(user, issuedAt) {
+		responseError(resp, req, 401, errCodeUnauthorized, "The token was issued before the account was suspended, log in again")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) authenticateAPIToken(resp http.ResponseWriter, req *http.Request, token, scope string) (authInfo, bool) {
+	apiToken, err := cfg.dbQueries.GetActiveAPIToken(req.Context(), auth.HashRefreshToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Unknown, revoked or expired API token")
+		responseUnauthorized(resp, req)
+		return authInfo{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting API token: %s", err)
+		responseInternalError(resp, req)
+		return authInfo{}, false
+	}
+
+	if scope == "" || !slices.Contains(apiToken.Scopes, scope) {
+		type details struct {
+			RequiredScope string `json:"required_scope,omitempty"`
+		}
+		message := "API tokens cannot be used here, log in instead"
+		if scope != "" {
+			message = fmt.Sprintf("The API token does not have the %s scope", scope)
+		}
+		responseErrorDetails(resp, req, 403, errCodeInsufficientScope, message, details{RequiredScope: scope})
+		return authInfo{}, false
+	}
+
+	if _, ok := cfg.checkTokenUser(resp, req, apiToken.UserID, apiToken.CreatedAt); !ok {
+		return authInfo{}, false
+	}
+
+	err = cfg.dbQueries.TouchAPIToken(req.Context(), apiToken.ID)
+	if err != nil {
+		log.Printf("Error updating API token: %s", err)
+	}
+
+	return authInfo{UserID: apiToken.UserID, Scopes: apiToken.Scopes}, true
+}
+
+// authUserID returns the user of a route wrapped in middlewareAuth.
+func authUserID(req *http.Request) uuid.UUID {
+	info, _ := req.Context().Value(authContextKey{}).(authInfo)
+	return info.UserID
+}
+
+// authViewerID returns the user of a route wrapped in middlewareOptionalAuth, if there is one.
+func authViewerID(req *http.Request) uuid.NullUUID {
+	info, ok := req.Context().Value(authContextKey{}).(authInfo)
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: info.UserID, Valid: true}
+}
+
+// authRole returns the current role of the user of an access token, or "" for anonymous requests and API tokens.
+func authRole(req *http.Request) string {
+	info, _ := req.Context().Value(authContextKey{}).(authInfo)
+	return info.Role
+}
+
+// authCanSeeHidden tells whether the viewer is a moderator, who sees the chirps hidden by moderators.
+func authCanSeeHidden(req *http.Request) bool {
+	role := authRole(req)
+	return role != "" && auth.HasRole(role, auth.RoleModerator)
+}
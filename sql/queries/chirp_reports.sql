@@ -0,0 +1,87 @@
+-- name: CreateChirpReport :one
+-- Users report a chirp once, reporting it again does nothing.
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, details, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetChirpReport :one
+SELECT sqlc.embed(chirp_reports), chirps.user_id AS author_id
+FROM chirp_reports
+JOIN chirps ON chirps.id = chirp_reports.chirp_id
+WHERE chirp_reports.id = $1;
+
+-- name: GetReportQueue :many
+-- The oldest reports come first. open_reports counts the open reports of the same chirp.
+SELECT sqlc.embed(chirp_reports),
+    chirps.user_id AS author_id,
+    chirps.body AS chirp_body,
+    chirps.hidden_at AS chirp_hidden_at,
+    (SELECT COUNT(*) FROM chirp_reports AS others
+        WHERE others.chirp_id = chirp_reports.chirp_id AND others.status = 'open') AS open_reports
+FROM chirp_reports
+JOIN chirps ON chirps.id = chirp_reports.chirp_id
+WHERE chirp_reports.status = sqlc.arg('status')
+AND (sqlc.narg('reason')::text IS NULL OR chirp_reports.reason = sqlc.narg('reason')::text)
+AND (sqlc.narg('assigned_to')::uuid IS NULL OR chirp_reports.assigned_to = sqlc.narg('assigned_to')::uuid)
+AND (NOT sqlc.arg('unassigned')::boolean OR chirp_reports.assigned_to IS NULL)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirp_reports.created_at, chirp_reports.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirp_reports.created_at ASC, chirp_reports.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: AssignChirpReport :one
+UPDATE chirp_reports
+SET assigned_to = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ModerateChirpReport :one
+-- Resolves an open report, applies the action and records it in one statement, so a report is never
+-- resolved without its action. hide takes the chirp down and resolves its other open reports, and
+-- suspend locks the author out and revokes their sessions. Nothing is changed when the report is not open.
+WITH resolved AS (
+    UPDATE chirp_reports
+    SET status = sqlc.arg('status'), resolved_at = NOW(),
+        assigned_to = COALESCE(chirp_reports.assigned_to, sqlc.arg('moderator_id')::uuid)
+    WHERE chirp_reports.id = sqlc.arg('report_id') AND chirp_reports.status = 'open'
+    RETURNING chirp_reports.id, chirp_reports.chirp_id
+), hidden AS (
+    UPDATE chirps
+    SET hidden_at = COALESCE(hidden_at, NOW())
+    FROM resolved
+    WHERE chirps.id = resolved.chirp_id AND sqlc.arg('action')::text = 'hide'
+), other_reports AS (
+    UPDATE chirp_reports
+    SET status = sqlc.arg('status'), resolved_at = NOW()
+    FROM resolved
+    WHERE chirp_reports.chirp_id = resolved.chirp_id AND chirp_reports.id <> resolved.id
+    AND chirp_reports.status = 'open' AND sqlc.arg('action')::text = 'hide'
+), suspended AS (
+    UPDATE users
+    SET suspended_until = sqlc.narg('suspended_until'), suspended_at = NOW(), updated_at = NOW()
+    FROM resolved
+    WHERE users.id = sqlc.arg('author_id')::uuid AND sqlc.arg('action')::text = 'suspend'
+), revoked_tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    FROM resolved
+    WHERE refresh_tokens.user_id = sqlc.arg('author_id')::uuid AND refresh_tokens.revoked_at IS NULL
+    AND sqlc.arg('action')::text = 'suspend'
+), revoked_sessions AS (
+    UPDATE sessions
+    SET revoked_at = NOW()
+    FROM resolved
+    WHERE sessions.user_id = sqlc.arg('author_id')::uuid AND sessions.revoked_at IS NULL
+    AND sqlc.arg('action')::text = 'suspend'
+)
+INSERT INTO moderation_actions (id, moderator_id, action, report_id, chirp_id, user_id, note, created_at)
+SELECT gen_random_uuid(), sqlc.arg('moderator_id')::uuid, sqlc.arg('action')::text, resolved.id, resolved.chirp_id,
+    sqlc.arg('author_id')::uuid, sqlc.arg('note'), NOW()
+FROM resolved
+RETURNING *;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, moderator_id, action, report_id, chirp_id, user_id, note, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW())
+RETURNING *;
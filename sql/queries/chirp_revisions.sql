@@ -0,0 +1,17 @@
+-- name: EditChirp :one
+-- The previous version is saved to chirp_revisions in the same statement.
+WITH revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), previous.id, previous.body, previous.updated_at, NOW()
+    FROM chirps AS previous
+    WHERE previous.id = sqlc.arg('id')
+)
+UPDATE chirps
+SET body = sqlc.arg('body'), updated_at = NOW(), edited_at = NOW()
+WHERE chirps.id = sqlc.arg('id')
+RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at DESC;
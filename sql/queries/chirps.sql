@@ -1,30 +1,211 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, kind, reference_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, kind, reference_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    'rechirp',
     $2
 )
+ON CONFLICT (user_id, reference_id) WHERE kind = 'rechirp' AND deleted_at IS NULL DO NOTHING
 RETURNING *;
 
 -- name: ResetChirps :exec
 DELETE FROM chirps;
 
--- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC;
+-- name: GetChirp :one
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE chirps.id = sqlc.arg('id') AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean);
+
+-- name: DeleteChirp :exec
+-- The chirp is only marked as deleted together with its rechirps, so it can be restored;
+-- PurgeDeletedChirps removes it for good later.
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE (id = $1 OR (kind = 'rechirp' AND reference_id = $1))
+AND deleted_at IS NULL;
 
--- name: GetChirpsAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+-- name: GetChirpsPageAsc :many
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
 
--- name: GetChirp :one
+-- name: GetChirpsPageDesc :many
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+WHERE deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count,
+    ts_rank(body_tsv, websearch_to_tsquery('english', sqlc.arg('search_query')))::real AS rank,
+    -- matches are marked with control characters that cannot be in the body, the handler escapes the rest
+    ts_headline('english', translate(body, chr(2) || chr(3), ''), websearch_to_tsquery('english', sqlc.arg('search_query')),
+        'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2')::text AS snippet
+FROM chirps
+WHERE body_tsv @@ websearch_to_tsquery('english', sqlc.arg('search_query'))
+AND deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+ORDER BY
+    CASE WHEN sqlc.arg('sort')::text = 'asc' THEN created_at END ASC,
+    CASE WHEN sqlc.arg('sort')::text = 'desc' THEN created_at END DESC,
+    rank DESC, id ASC
+LIMIT sqlc.arg('page_limit')
+OFFSET sqlc.arg('page_offset');
+
+-- name: GetTimeline :many
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1 FROM chirps AS parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps AS child WHERE child.id = sqlc.arg('id'))
+    UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1 FROM chirps AS parent
+    JOIN ancestors ON parent.id = ancestors.in_reply_to
+)
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count
+FROM ancestors
+JOIN chirps ON chirps.id = ancestors.id
+WHERE chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id, depth) AS (
+    SELECT reply.id, 1 FROM chirps AS reply
+    WHERE reply.in_reply_to = sqlc.arg('id')::uuid
+    UNION ALL
+    SELECT reply.id, descendants.depth + 1 FROM chirps AS reply
+    JOIN descendants ON reply.in_reply_to = descendants.id
+)
+SELECT sqlc.embed(chirps),
+    (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+    EXISTS (
+        SELECT 1 FROM chirp_likes
+        WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+    ) AS liked_by_me,
+    (SELECT COUNT(*) FROM chirps AS replies WHERE replies.in_reply_to = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL) AS reply_count,
+    descendants.depth
+FROM descendants
+JOIN chirps ON chirps.id = descendants.id
+WHERE chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpsByIDs :many
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = ANY(sqlc.arg('ids')::uuid[]) AND deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR sqlc.arg('include_hidden')::boolean);
 
--- name: DeleteChirp :exec
+-- name: GetDeletedChirp :one
+-- Hidden chirps are not restored by their authors.
+SELECT * FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL AND hidden_at IS NULL;
+
+-- name: RestoreChirp :one
+-- Rechirps deleted together with the chirp come back with it. A rechirp comes back only while
+-- its original is not deleted.
+WITH rechirps AS (
+    UPDATE chirps AS rechirps
+    SET deleted_at = NULL
+    FROM chirps AS original
+    WHERE original.id = sqlc.arg('id')
+    AND rechirps.kind = 'rechirp' AND rechirps.reference_id = original.id
+    AND rechirps.deleted_at = original.deleted_at
+)
+UPDATE chirps
+SET deleted_at = NULL
+WHERE chirps.id = sqlc.arg('id') AND chirps.deleted_at IS NOT NULL
+AND (chirps.kind <> 'rechirp' OR EXISTS (
+    SELECT 1 FROM chirps AS original
+    WHERE original.id = chirps.reference_id AND original.deleted_at IS NULL
+))
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE deleted_at < $1;
+
+-- name: CountUserChirpsSince :one
+-- Deleted chirps are counted too, so deleting does not free up the limit.
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2;
@@ -0,0 +1,55 @@
+-- name: GetFilteredWords :many
+SELECT * FROM filtered_words
+ORDER BY word ASC;
+
+-- name: UpsertFilteredWord :one
+INSERT INTO filtered_words (word, policy, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (word) DO UPDATE
+SET policy = EXCLUDED.policy, disabled = FALSE, updated_at = NOW()
+RETURNING *;
+
+-- name: DisableFilteredWord :exec
+-- The row is kept, so a word from the word list file does not come back after a restart.
+INSERT INTO filtered_words (word, policy, disabled, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    TRUE,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (word) DO UPDATE
+SET disabled = TRUE, updated_at = NOW();
+
+-- name: CreateFlaggedChirp :exec
+INSERT INTO flagged_chirps (chirp_id, words, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (chirp_id) DO UPDATE
+SET words = EXCLUDED.words, created_at = NOW();
+
+-- name: GetFlaggedChirps :many
+-- The oldest flags come first. Deleted chirps are left out.
+SELECT sqlc.embed(chirps),
+    flagged_chirps.words,
+    flagged_chirps.created_at AS flagged_at
+FROM flagged_chirps
+JOIN chirps ON chirps.id = flagged_chirps.chirp_id
+WHERE chirps.deleted_at IS NULL
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (flagged_chirps.created_at, flagged_chirps.chirp_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY flagged_chirps.created_at ASC, flagged_chirps.chirp_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteFlaggedChirp :execrows
+DELETE FROM flagged_chirps
+WHERE chirp_id = $1;
@@ -0,0 +1,26 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1;
+
+-- name: InsertLoginAttempt :execrows
+-- Inserts nothing when another login has inserted the key in the meantime.
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: SwapLoginAttempt :execrows
+-- Updates nothing when another login has changed the key in the meantime.
+UPDATE login_attempts
+SET failures = sqlc.arg('failures'),
+    last_failure_at = sqlc.arg('last_failure_at')
+WHERE key = sqlc.arg('key')
+    AND failures = sqlc.arg('old_failures')
+    AND last_failure_at = sqlc.arg('old_last_failure_at');
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: PruneLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE last_failure_at < $1;
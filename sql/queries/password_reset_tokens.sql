@@ -0,0 +1,23 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at, used_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3,
+    NULL
+);
+
+-- name: UsePasswordResetToken :one
+-- Marks the token used, together with the other tokens of the user, so that only one reset goes through.
+WITH token AS (
+    SELECT password_reset_tokens.user_id FROM password_reset_tokens
+    WHERE password_reset_tokens.token_hash = $1
+    AND password_reset_tokens.used_at IS NULL AND password_reset_tokens.expires_at > NOW()
+    FOR UPDATE
+)
+UPDATE password_reset_tokens
+SET used_at = NOW()
+FROM token
+WHERE password_reset_tokens.user_id = token.user_id AND password_reset_tokens.used_at IS NULL
+RETURNING password_reset_tokens.user_id;
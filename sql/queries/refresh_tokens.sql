@@ -1,24 +1,42 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    NULL
+    NULL,
+    $4
 )
 RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT user_id FROM refresh_tokens
-WHERE token = $1;
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
 
--- name: GetExpirationFromRefreshToken :one
-SELECT expires_at, revoked_at FROM refresh_tokens
-WHERE token = $1;
+-- name: RotateRefreshToken :one
+-- Claims the token and inserts the next one of its family in one statement, so a failed
+-- refresh does not use the token up. Only one request can rotate a token, the others get no rows.
+WITH claimed AS (
+    UPDATE refresh_tokens
+    SET rotated_at = NOW(), updated_at = NOW()
+    WHERE refresh_tokens.token_hash = sqlc.arg('token_hash')
+    AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+    RETURNING user_id, family_id
+)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id)
+SELECT sqlc.arg('new_token_hash'), NOW(), NOW(), claimed.user_id, sqlc.arg('expires_at'), NULL, claimed.family_id
+FROM claimed
+RETURNING *;
 
--- name: RevokeRefreshToken :exec
+-- name: RevokeRefreshTokenFamily :exec
+-- The session of the family ends together with it.
+WITH session AS (
+    UPDATE sessions
+    SET revoked_at = NOW()
+    WHERE sessions.id = $1 AND sessions.revoked_at IS NULL
+)
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE family_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,52 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, created_at, last_used_at, user_agent, ip_address, revoked_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    NULL
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = NOW(), user_agent = $2, ip_address = $3
+WHERE id = $1;
+
+-- name: GetActiveSessions :many
+-- A session is active while its latest refresh token can still be used.
+SELECT * FROM sessions
+WHERE sessions.user_id = $1 AND sessions.revoked_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE refresh_tokens.family_id = sessions.id
+    AND refresh_tokens.rotated_at IS NULL AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > NOW()
+)
+ORDER BY sessions.last_used_at DESC;
+
+-- name: RevokeSession :one
+WITH tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    FROM sessions
+    WHERE sessions.id = sqlc.arg('id') AND sessions.user_id = sqlc.arg('user_id')
+    AND refresh_tokens.family_id = sessions.id AND refresh_tokens.revoked_at IS NULL
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE sessions.id = sqlc.arg('id') AND sessions.user_id = sqlc.arg('user_id') AND sessions.revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeUserSessions :execrows
+WITH tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    WHERE refresh_tokens.user_id = $1 AND refresh_tokens.revoked_at IS NULL
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE sessions.user_id = $1 AND sessions.revoked_at IS NULL;
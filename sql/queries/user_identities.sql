@@ -0,0 +1,36 @@
+-- name: CreateOIDCState :exec
+INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, user_id, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, NOW(), $6);
+
+-- name: ClaimOIDCState :one
+-- A state can be used only once, whether the login succeeds or not.
+DELETE FROM oidc_states
+WHERE state_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredOIDCStates :exec
+DELETE FROM oidc_states
+WHERE expires_at <= NOW();
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $2, last_login_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE user_id = $1 AND provider = $2;
@@ -17,9 +17,10 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdateEmailPassword :one
+-- name: UpdatePendingEmailPassword :one
+-- The email itself changes only when the pending email is verified.
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET pending_email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
@@ -27,4 +28,72 @@ RETURNING *;
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: UpdatePassword :one
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ConfirmEmail :one
+UPDATE users
+SET email = sqlc.arg('email'), pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg('id') AND (email = sqlc.arg('email') OR pending_email = sqlc.arg('email'))
+RETURNING *;
+
+-- name: CreateExternalUser :one
+-- Users from identity providers have no password (hashed_password keeps its default),
+-- and the provider has verified their email already. The identity is inserted in the same
+-- statement, so no user is left without a way to log in when one of the inserts fails.
+WITH new_user AS (
+    SELECT gen_random_uuid() AS id
+), identity AS (
+    INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+    SELECT gen_random_uuid(), new_user.id, sqlc.arg('provider'), sqlc.arg('subject'), sqlc.arg('identity_email'), NOW(), NOW()
+    FROM new_user
+)
+INSERT INTO users (id, created_at, updated_at, email, email_verified_at)
+SELECT new_user.id, NOW(), NOW(), sqlc.arg('email'), NOW()
+FROM new_user
+RETURNING *;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PromoteFirstAdmin :one
+-- Does nothing once there is an admin, later admins are promoted by admins.
+UPDATE users
+SET role = 'admin', updated_at = NOW()
+WHERE users.email = $1 AND NOT EXISTS (SELECT 1 FROM users AS admins WHERE admins.role = 'admin')
+RETURNING *;
+
+-- name: CountAdmins :one
+SELECT COUNT(*) FROM users
+WHERE role = 'admin';
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_until = $2, suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: BanUser :one
+UPDATE users
+SET banned = TRUE, suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: LiftUserSuspension :one
+-- suspended_at stays, so the tokens from before the suspension keep not working.
+UPDATE users
+SET suspended_until = NULL, banned = FALSE, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
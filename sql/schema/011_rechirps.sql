@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD kind TEXT NOT NULL
+CONSTRAINT default_chirp_kind DEFAULT 'chirp';
+
+ALTER TABLE chirps
+ADD reference_id UUID DEFAULT NULL
+REFERENCES chirps(id) ON DELETE SET NULL;
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirp_kinds CHECK (kind IN ('chirp', 'rechirp', 'quote')),
+ADD CONSTRAINT chirp_without_reference CHECK (kind <> 'chirp' OR reference_id IS NULL),
+ADD CONSTRAINT rechirp_with_reference CHECK (kind <> 'rechirp' OR reference_id IS NOT NULL);
+
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, reference_id)
+WHERE kind = 'rechirp';
+
+-- Rechirps go away together with the original chirp (like chirps go away with their user),
+-- while quotes keep their own body and only lose the reference.
+-- +goose StatementBegin
+CREATE FUNCTION delete_rechirps() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM chirps WHERE kind = 'rechirp' AND reference_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_delete_rechirps
+BEFORE DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION delete_rechirps();
+
+-- +goose Down
+DROP TRIGGER chirps_delete_rechirps ON chirps;
+DROP FUNCTION delete_rechirps;
+DROP INDEX chirps_one_rechirp_per_user_idx;
+
+ALTER TABLE chirps
+DROP COLUMN reference_id,
+DROP COLUMN kind;
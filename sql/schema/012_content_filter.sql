@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE filtered_words (
+    word TEXT PRIMARY KEY,
+    policy TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT filtered_word_policies CHECK (policy IN ('mask', 'reject', 'flag'))
+);
+
+INSERT INTO filtered_words (word, policy, created_at, updated_at)
+VALUES
+    ('kerfuffle', 'mask', NOW(), NOW()),
+    ('sharbert', 'mask', NOW(), NOW()),
+    ('fornax', 'mask', NOW(), NOW());
+
+CREATE TABLE flagged_chirps (
+    chirp_id UUID PRIMARY KEY,
+    words TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE flagged_chirps;
+DROP TABLE filtered_words;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD deleted_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+-- A deleted rechirp must not stop the user from rechirping the chirp again.
+DROP INDEX chirps_one_rechirp_per_user_idx;
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, reference_id)
+WHERE kind = 'rechirp' AND deleted_at IS NULL;
+
+-- +goose Down
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX chirps_one_rechirp_per_user_idx;
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, reference_id)
+WHERE kind = 'rechirp';
+
+DROP INDEX chirps_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Only hashes of refresh tokens are stored, the tokens themselves stay with the clients.
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- Every login starts a family of tokens, each refresh replaces the token with the next one of the family.
+ALTER TABLE refresh_tokens
+ADD family_id UUID,
+ADD rotated_at TIMESTAMP DEFAULT NULL;
+
+UPDATE refresh_tokens
+SET family_id = gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+-- The hashes cannot be turned back into tokens, so everyone has to log in again.
+DELETE FROM refresh_tokens;
+
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN rotated_at,
+DROP COLUMN family_id;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Failed logins per account ("account:<email>") and per IP address ("ip:<address>").
+CREATE TABLE login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX login_attempts_last_failure_at_idx ON login_attempts (last_failure_at);
+
+-- +goose Down
+DROP TABLE login_attempts;
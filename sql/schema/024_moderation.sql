@@ -0,0 +1,54 @@
+-- +goose Up
+-- Hidden chirps are taken down by moderators; unlike deleted chirps their authors cannot bring them back.
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP DEFAULT NULL;
+
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP DEFAULT NULL;
+
+CREATE TABLE chirp_reports (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    reporter_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    assigned_to UUID DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP DEFAULT NULL,
+    UNIQUE (chirp_id, reporter_id),
+    CONSTRAINT chirp_report_reasons CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+    CONSTRAINT chirp_report_statuses CHECK (status IN ('open', 'actioned', 'dismissed')),
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (assigned_to) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX chirp_reports_queue_idx ON chirp_reports (status, created_at, id);
+
+-- moderation_actions is the audit log of the moderators' decisions. It outlives
+-- the reports, chirps and users it points at.
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    moderator_id UUID DEFAULT NULL,
+    action TEXT NOT NULL,
+    report_id UUID DEFAULT NULL,
+    chirp_id UUID DEFAULT NULL,
+    user_id UUID DEFAULT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (report_id) REFERENCES chirp_reports(id) ON DELETE SET NULL,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE chirp_reports;
+
+ALTER TABLE users
+DROP COLUMN suspended_until;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- suspended_at is when the user was last suspended or banned; tokens issued before it stop working.
+ALTER TABLE users
+ADD COLUMN banned BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN suspended_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN suspended_at,
+DROP COLUMN banned;
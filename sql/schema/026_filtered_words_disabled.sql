@@ -0,0 +1,11 @@
+-- +goose Up
+-- A disabled word was removed through the admin endpoints and stays removed even if the word list file has it.
+ALTER TABLE filtered_words
+ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+DELETE FROM filtered_words
+WHERE disabled;
+
+ALTER TABLE filtered_words
+DROP COLUMN disabled;
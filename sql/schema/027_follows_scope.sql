@@ -0,0 +1,8 @@
+-- +goose Up
+-- The profile:write scope only ever covered following users, so it is named after that.
+UPDATE api_tokens
+SET scopes = array_replace(scopes, 'profile:write', 'follows:write');
+
+-- +goose Down
+UPDATE api_tokens
+SET scopes = array_replace(scopes, 'follows:write', 'profile:write');
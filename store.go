@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// store is the part of database.Queries that the handlers use, so that they can be tested
+// without Postgres. *database.Queries is the store of the server.
+type store interface {
+	BanUser(ctx context.Context, id uuid.UUID) (database.User, error)
+	ConfirmEmail(ctx context.Context, arg database.ConfirmEmailParams) (database.User, error)
+	CreateExternalUser(ctx context.Context, arg database.CreateExternalUserParams) (database.User, error)
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUserByEmail(ctx context.Context, email sql.NullString) (database.User, error)
+	LiftUserSuspension(ctx context.Context, id uuid.UUID) (database.User, error)
+	ResetUsers(ctx context.Context) error
+	SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error)
+	UpdateChirpyRed(ctx context.Context, id uuid.UUID) (database.User, error)
+	UpdatePassword(ctx context.Context, arg database.UpdatePasswordParams) (database.User, error)
+	UpdatePendingEmailPassword(ctx context.Context, arg database.UpdatePendingEmailPasswordParams) (database.User, error)
+	UpdateUserRole(ctx context.Context, arg database.UpdateUserRoleParams) (database.User, error)
+
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RotateRefreshToken(ctx context.Context, arg database.RotateRefreshTokenParams) (database.RefreshToken, error)
+
+	CreateSession(ctx context.Context, arg database.CreateSessionParams) (database.Session, error)
+	GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]database.Session, error)
+	RevokeSession(ctx context.Context, arg database.RevokeSessionParams) (database.Session, error)
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) (int64, error)
+	TouchSession(ctx context.Context, arg database.TouchSessionParams) error
+
+	CreatePasswordResetToken(ctx context.Context, arg database.CreatePasswordResetTokenParams) error
+	UsePasswordResetToken(ctx context.Context, tokenHash string) (uuid.UUID, error)
+
+	CreateEmailVerificationToken(ctx context.Context, arg database.CreateEmailVerificationTokenParams) error
+	UseEmailVerificationToken(ctx context.Context, tokenHash string) (database.UseEmailVerificationTokenRow, error)
+
+	ClaimLoginChallenge(ctx context.Context, tokenHash string) (int64, error)
+	CreateLoginChallenge(ctx context.Context, arg database.CreateLoginChallengeParams) error
+	CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error
+	EnableUserTOTP(ctx context.Context, userID uuid.UUID) (database.UserTotp, error)
+	GetLoginChallenge(ctx context.Context, tokenHash string) (database.LoginChallenge, error)
+	GetUserTOTP(ctx context.Context, userID uuid.UUID) (database.UserTotp, error)
+	UpsertUserTOTP(ctx context.Context, arg database.UpsertUserTOTPParams) (database.UserTotp, error)
+	UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error)
+	UseTOTPStep(ctx context.Context, arg database.UseTOTPStepParams) (int64, error)
+
+	ClaimOIDCState(ctx context.Context, stateHash string) (database.OidcState, error)
+	CreateOIDCState(ctx context.Context, arg database.CreateOIDCStateParams) error
+	CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error)
+	DeleteExpiredOIDCStates(ctx context.Context) error
+	DeleteUserIdentity(ctx context.Context, arg database.DeleteUserIdentityParams) (int64, error)
+	GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]database.UserIdentity, error)
+	GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error)
+	TouchUserIdentity(ctx context.Context, arg database.TouchUserIdentityParams) error
+
+	CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error)
+	GetAPITokens(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error)
+	GetActiveAPIToken(ctx context.Context, tokenHash string) (database.ApiToken, error)
+	RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error)
+	TouchAPIToken(ctx context.Context, id uuid.UUID) error
+
+	CountUserChirpsSince(ctx context.Context, arg database.CountUserChirpsSinceParams) (int64, error)
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	CreateRechirp(ctx context.Context, arg database.CreateRechirpParams) (database.Chirp, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	GetChirp(ctx context.Context, arg database.GetChirpParams) (database.GetChirpRow, error)
+	GetChirpAncestors(ctx context.Context, arg database.GetChirpAncestorsParams) ([]database.GetChirpAncestorsRow, error)
+	GetChirpDescendants(ctx context.Context, arg database.GetChirpDescendantsParams) ([]database.GetChirpDescendantsRow, error)
+	GetChirpsByIDs(ctx context.Context, arg database.GetChirpsByIDsParams) ([]database.Chirp, error)
+	GetChirpsPageAsc(ctx context.Context, arg database.GetChirpsPageAscParams) ([]database.GetChirpsPageAscRow, error)
+	GetChirpsPageDesc(ctx context.Context, arg database.GetChirpsPageDescParams) ([]database.GetChirpsPageDescRow, error)
+	GetDeletedChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetTimeline(ctx context.Context, arg database.GetTimelineParams) ([]database.GetTimelineRow, error)
+	PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error)
+	RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error)
+
+	EditChirp(ctx context.Context, arg database.EditChirpParams) (database.Chirp, error)
+	GetChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error)
+
+	CreateChirpLike(ctx context.Context, arg database.CreateChirpLikeParams) error
+	DeleteChirpLike(ctx context.Context, arg database.DeleteChirpLikeParams) error
+
+	CreateFollow(ctx context.Context, arg database.CreateFollowParams) error
+	DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) error
+	GetFollowers(ctx context.Context, arg database.GetFollowersParams) ([]database.GetFollowersRow, error)
+	GetFollowing(ctx context.Context, arg database.GetFollowingParams) ([]database.GetFollowingRow, error)
+
+	CreateFlaggedChirp(ctx context.Context, arg database.CreateFlaggedChirpParams) error
+	DeleteFlaggedChirp(ctx context.Context, chirpID uuid.UUID) (int64, error)
+	DisableFilteredWord(ctx context.Context, arg database.DisableFilteredWordParams) error
+	GetFilteredWords(ctx context.Context) ([]database.FilteredWord, error)
+	GetFlaggedChirps(ctx context.Context, arg database.GetFlaggedChirpsParams) ([]database.GetFlaggedChirpsRow, error)
+	UpsertFilteredWord(ctx context.Context, arg database.UpsertFilteredWordParams) (database.FilteredWord, error)
+
+	AssignChirpReport(ctx context.Context, arg database.AssignChirpReportParams) (database.ChirpReport, error)
+	CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error)
+	CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error)
+	GetChirpReport(ctx context.Context, id uuid.UUID) (database.GetChirpReportRow, error)
+	GetReportQueue(ctx context.Context, arg database.GetReportQueueParams) ([]database.GetReportQueueRow, error)
+	ModerateChirpReport(ctx context.Context, arg database.ModerateChirpReportParams) (database.ModerationAction, error)
+}
@@ -0,0 +1,1452 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ValeriiaGrebneva/Chirpy/internal/auth"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/contentfilter"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/database"
+	"github.com/ValeriiaGrebneva/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// memoryStore behaves like the queries on Postgres, for the handler tests.
+// Calling a query it does not implement panics through the nil store.
+type memoryStore struct {
+	store
+
+	mu            sync.Mutex
+	users         map[uuid.UUID]database.User
+	refreshTokens map[string]database.RefreshToken
+	sessions      map[uuid.UUID]database.Session
+	resetTokens   map[string]database.PasswordResetToken
+	verifyTokens  map[string]database.EmailVerificationToken
+	totps         map[uuid.UUID]database.UserTotp
+	recoveryCodes []database.TotpRecoveryCode
+	challenges    map[string]database.LoginChallenge
+	states        map[string]database.OidcState
+	identities    []database.UserIdentity
+	apiTokens     map[uuid.UUID]database.ApiToken
+	chirps        map[uuid.UUID]database.Chirp
+	likes         map[likeKey]bool
+	revisions     []database.ChirpRevision
+	filterWords   map[string]database.FilteredWord
+	flagged       map[uuid.UUID]database.FlaggedChirp
+	follows       []database.Follow
+	reports       []database.ChirpReport
+	actions       []database.ModerationAction
+	// revoked are the users whose sessions were revoked.
+	revoked []uuid.UUID
+	// lastCreatedAt keeps the rows in the order they were added, like NOW() of separate statements.
+	lastCreatedAt time.Time
+}
+
+type likeKey struct {
+	userID  uuid.UUID
+	chirpID uuid.UUID
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:         map[uuid.UUID]database.User{},
+		refreshTokens: map[string]database.RefreshToken{},
+		sessions:      map[uuid.UUID]database.Session{},
+		resetTokens:   map[string]database.PasswordResetToken{},
+		verifyTokens:  map[string]database.EmailVerificationToken{},
+		totps:         map[uuid.UUID]database.UserTotp{},
+		challenges:    map[string]database.LoginChallenge{},
+		states:        map[string]database.OidcState{},
+		apiTokens:     map[uuid.UUID]database.ApiToken{},
+		chirps:        map[uuid.UUID]database.Chirp{},
+		likes:         map[likeKey]bool{},
+		filterWords:   map[string]database.FilteredWord{},
+		flagged:       map[uuid.UUID]database.FlaggedChirp{},
+	}
+}
+
+// newTestConfig returns a config on an empty memoryStore, with cheap password hashing
+// and the defaults of the server.
+func newTestConfig(t *testing.T) (*apiConfig, *memoryStore) {
+	t.Helper()
+	key, err := auth.GenerateKey(auth.AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	hasher, err := auth.NewPasswordHasher(8*1024, 1, 1)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error = %v", err)
+	}
+	policy, err := auth.LoadPasswordPolicy(8, 64, "")
+	if err != nil {
+		t.Fatalf("LoadPasswordPolicy() error = %v", err)
+	}
+
+	store := newMemoryStore()
+	cfg := &apiConfig{
+		dbQueries:          store,
+		mailer:             newMemoryMailer(),
+		jwtKeys:            auth.NewKeySet(key),
+		passwordHasher:     hasher,
+		passwordPolicy:     policy,
+		contentFilter:      contentfilter.New(map[string]contentfilter.Policy{}),
+		maxChirpLength:     140,
+		maxChirpLengthRed:  280,
+		chirpEditWindow:    15 * time.Minute,
+		chirpRestorePeriod: 24 * time.Hour,
+		chirpRetention:     30 * 24 * time.Hour,
+	}
+	cfg.loginAccounts, cfg.loginIPs, err = loadLoginLimiters(nil, "memory", 10, 100, time.Minute)
+	if err != nil {
+		t.Fatalf("loadLoginLimiters() error = %v", err)
+	}
+	return cfg, store
+}
+
+// memoryMailer keeps the sent messages for the tests to read.
+type memoryMailer struct {
+	sent chan mailer.Message
+}
+
+func newMemoryMailer() *memoryMailer {
+	return &memoryMailer{sent: make(chan mailer.Message, 16)}
+}
+
+func (m *memoryMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// next waits for the next sent message.
+func (m *memoryMailer) next(t *testing.T) mailer.Message {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No message was sent")
+		return mailer.Message{}
+	}
+}
+
+func (m *memoryStore) addUser(role string) database.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	user := database.User{
+		ID:              uuid.New(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+		Role:            role,
+	}
+	m.users[user.ID] = user
+	return user
+}
+
+// setUser replaces the user, keeping the ID.
+func (m *memoryStore) setUser(user database.User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.ID] = user
+}
+
+func (m *memoryStore) user(id uuid.UUID) database.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[id]
+}
+
+// now returns the current time at the precision of Postgres, after every earlier call.
+func (m *memoryStore) now() time.Time {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(m.lastCreatedAt) {
+		now = m.lastCreatedAt.Add(time.Microsecond)
+	}
+	m.lastCreatedAt = now
+	return now
+}
+
+// addExternalUser adds a user without a password, like the users signed up by an identity provider,
+// but without an identity.
+func (m *memoryStore) addExternalUser(email string) database.User {
+	user := m.addUser(auth.RoleUser)
+	user.Email = sql.NullString{String: email, Valid: true}
+	user.HashedPassword = unsetPassword
+	user.EmailVerifiedAt = sql.NullTime{Time: user.CreatedAt, Valid: true}
+	m.setUser(user)
+	return user
+}
+
+func (m *memoryStore) addChirp(authorID uuid.UUID) database.Chirp {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "Chirp", UserID: authorID, Kind: "chirp"}
+	m.chirps[chirp.ID] = chirp
+	return chirp
+}
+
+func (m *memoryStore) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *memoryStore) GetUserByEmail(ctx context.Context, email sql.NullString) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *memoryStore) CreateExternalUser(ctx context.Context, arg database.CreateExternalUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.Email == arg.Email {
+			return database.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+	for _, identity := range m.identities {
+		if identity.Provider == arg.Provider && identity.Subject == arg.Subject {
+			return database.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+	now := time.Now().UTC()
+	user := database.User{
+		ID:              uuid.New(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Email:           arg.Email,
+		HashedPassword:  unsetPassword,
+		EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+		Role:            auth.RoleUser,
+	}
+	m.users[user.ID] = user
+	m.identities = append(m.identities, database.UserIdentity{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Provider:    arg.Provider,
+		Subject:     arg.Subject,
+		Email:       arg.IdentityEmail,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	return user, nil
+}
+
+func (m *memoryStore) UpdatePassword(ctx context.Context, arg database.UpdatePasswordParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.HashedPassword = arg.HashedPassword
+	m.users[arg.ID] = user
+	return user, nil
+}
+
+func (m *memoryStore) SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.SuspendedUntil = arg.SuspendedUntil
+	user.SuspendedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.users[arg.ID] = user
+	return user, nil
+}
+
+func (m *memoryStore) BanUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.Banned = true
+	user.SuspendedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.users[id] = user
+	return user, nil
+}
+
+func (m *memoryStore) LiftUserSuspension(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.SuspendedUntil = sql.NullTime{}
+	user.Banned = false
+	m.users[id] = user
+	return user, nil
+}
+
+func (m *memoryStore) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	token := database.RefreshToken{
+		TokenHash: arg.TokenHash,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		FamilyID:  arg.FamilyID,
+	}
+	m.refreshTokens[arg.TokenHash] = token
+	return token, nil
+}
+
+func (m *memoryStore) GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.refreshTokens[tokenHash]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return token, nil
+}
+
+func (m *memoryStore) RotateRefreshToken(ctx context.Context, arg database.RotateRefreshTokenParams) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	claimed, ok := m.refreshTokens[arg.TokenHash]
+	now := time.Now().UTC()
+	if !ok || claimed.RotatedAt.Valid || claimed.RevokedAt.Valid || !claimed.ExpiresAt.After(now) {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	claimed.RotatedAt = sql.NullTime{Time: now, Valid: true}
+	m.refreshTokens[arg.TokenHash] = claimed
+	token := database.RefreshToken{
+		TokenHash: arg.NewTokenHash,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    claimed.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		FamilyID:  claimed.FamilyID,
+	}
+	m.refreshTokens[arg.NewTokenHash] = token
+	return token, nil
+}
+
+func (m *memoryStore) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, token := range m.refreshTokens {
+		if token.FamilyID == familyID && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+			m.refreshTokens[hash] = token
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) CreateSession(ctx context.Context, arg database.CreateSessionParams) (database.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	session := database.Session{
+		ID:         uuid.New(),
+		UserID:     arg.UserID,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		UserAgent:  arg.UserAgent,
+		IpAddress:  arg.IpAddress,
+	}
+	m.sessions[session.ID] = session
+	return session, nil
+}
+
+func (m *memoryStore) TouchSession(ctx context.Context, arg database.TouchSessionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[arg.ID]
+	if !ok {
+		return nil
+	}
+	session.LastUsedAt = m.now()
+	session.UserAgent = arg.UserAgent
+	session.IpAddress = arg.IpAddress
+	m.sessions[arg.ID] = session
+	return nil
+}
+
+func (m *memoryStore) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]database.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	sessions := []database.Session{}
+	for _, session := range m.sessions {
+		if session.UserID != userID || session.RevokedAt.Valid {
+			continue
+		}
+		for _, token := range m.refreshTokens {
+			if token.FamilyID == session.ID && !token.RotatedAt.Valid && !token.RevokedAt.Valid && token.ExpiresAt.After(now) {
+				sessions = append(sessions, session)
+				break
+			}
+		}
+	}
+	slices.SortFunc(sessions, func(a, b database.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (m *memoryStore) RevokeSession(ctx context.Context, arg database.RevokeSessionParams) (database.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[arg.ID]
+	if !ok || session.UserID != arg.UserID || session.RevokedAt.Valid {
+		return database.Session{}, sql.ErrNoRows
+	}
+	now := time.Now().UTC()
+	for hash, token := range m.refreshTokens {
+		if token.FamilyID == session.ID && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			m.refreshTokens[hash] = token
+		}
+	}
+	session.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	m.sessions[session.ID] = session
+	return session, nil
+}
+
+func (m *memoryStore) RevokeUserSessions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revokeUserSessions(userID), nil
+}
+
+// revokeUserSessions revokes the sessions and refresh tokens of the user, with m.mu held.
+func (m *memoryStore) revokeUserSessions(userID uuid.UUID) int64 {
+	now := time.Now().UTC()
+	for hash, token := range m.refreshTokens {
+		if token.UserID == userID && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			m.refreshTokens[hash] = token
+		}
+	}
+	revoked := int64(0)
+	for id, session := range m.sessions {
+		if session.UserID == userID && !session.RevokedAt.Valid {
+			session.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			m.sessions[id] = session
+			revoked++
+		}
+	}
+	m.revoked = append(m.revoked, userID)
+	return revoked
+}
+
+func (m *memoryStore) CreatePasswordResetToken(ctx context.Context, arg database.CreatePasswordResetTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resetTokens[arg.TokenHash] = database.PasswordResetToken{
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (m *memoryStore) UsePasswordResetToken(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.resetTokens[tokenHash]
+	now := time.Now().UTC()
+	if !ok || token.UsedAt.Valid || !token.ExpiresAt.After(now) {
+		return uuid.UUID{}, sql.ErrNoRows
+	}
+	for hash, other := range m.resetTokens {
+		if other.UserID == token.UserID && !other.UsedAt.Valid {
+			other.UsedAt = sql.NullTime{Time: now, Valid: true}
+			m.resetTokens[hash] = other
+		}
+	}
+	return token.UserID, nil
+}
+
+func (m *memoryStore) UpdatePendingEmailPassword(ctx context.Context, arg database.UpdatePendingEmailPasswordParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.PendingEmail = arg.PendingEmail
+	user.HashedPassword = arg.HashedPassword
+	user.UpdatedAt = time.Now().UTC()
+	m.users[arg.ID] = user
+	return user, nil
+}
+
+func (m *memoryStore) CreateEmailVerificationToken(ctx context.Context, arg database.CreateEmailVerificationTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.verifyTokens[arg.TokenHash] = database.EmailVerificationToken{
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		Email:     arg.Email,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (m *memoryStore) UseEmailVerificationToken(ctx context.Context, tokenHash string) (database.UseEmailVerificationTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.verifyTokens[tokenHash]
+	now := time.Now().UTC()
+	if !ok || token.UsedAt.Valid || !token.ExpiresAt.After(now) {
+		return database.UseEmailVerificationTokenRow{}, sql.ErrNoRows
+	}
+	token.UsedAt = sql.NullTime{Time: now, Valid: true}
+	m.verifyTokens[tokenHash] = token
+	return database.UseEmailVerificationTokenRow{UserID: token.UserID, Email: token.Email}, nil
+}
+
+func (m *memoryStore) ConfirmEmail(ctx context.Context, arg database.ConfirmEmailParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[arg.ID]
+	if !ok || (user.Email != arg.Email && user.PendingEmail != arg.Email) {
+		return database.User{}, sql.ErrNoRows
+	}
+	for _, other := range m.users {
+		if other.ID != user.ID && other.Email == arg.Email {
+			return database.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+	now := time.Now().UTC()
+	user.Email = arg.Email
+	user.PendingEmail = sql.NullString{}
+	user.EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
+	user.UpdatedAt = now
+	m.users[arg.ID] = user
+	return user, nil
+}
+
+func (m *memoryStore) UpsertUserTOTP(ctx context.Context, arg database.UpsertUserTOTPParams) (database.UserTotp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totps[arg.UserID].EnabledAt.Valid {
+		return database.UserTotp{}, sql.ErrNoRows
+	}
+	userTOTP := database.UserTotp{UserID: arg.UserID, Secret: arg.Secret, CreatedAt: time.Now().UTC()}
+	m.totps[arg.UserID] = userTOTP
+	return userTOTP, nil
+}
+
+func (m *memoryStore) GetUserTOTP(ctx context.Context, userID uuid.UUID) (database.UserTotp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userTOTP, ok := m.totps[userID]
+	if !ok {
+		return database.UserTotp{}, sql.ErrNoRows
+	}
+	return userTOTP, nil
+}
+
+func (m *memoryStore) EnableUserTOTP(ctx context.Context, userID uuid.UUID) (database.UserTotp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userTOTP, ok := m.totps[userID]
+	if !ok || userTOTP.EnabledAt.Valid {
+		return database.UserTotp{}, sql.ErrNoRows
+	}
+	userTOTP.EnabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.totps[userID] = userTOTP
+	return userTOTP, nil
+}
+
+func (m *memoryStore) UseTOTPStep(ctx context.Context, arg database.UseTOTPStepParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userTOTP, ok := m.totps[arg.UserID]
+	if !ok || userTOTP.LastUsedStep >= arg.LastUsedStep {
+		return 0, nil
+	}
+	userTOTP.LastUsedStep = arg.LastUsedStep
+	m.totps[arg.UserID] = userTOTP
+	return 1, nil
+}
+
+func (m *memoryStore) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	m.DeleteRecoveryCodes(ctx, userID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.totps, userID)
+	return nil
+}
+
+func (m *memoryStore) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recoveryCodes = append(m.recoveryCodes, database.TotpRecoveryCode{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		CodeHash:  arg.CodeHash,
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+func (m *memoryStore) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(code database.TotpRecoveryCode) bool {
+		return code.UserID == userID
+	})
+	return nil
+}
+
+func (m *memoryStore) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, code := range m.recoveryCodes {
+		if code.UserID == arg.UserID && code.CodeHash == arg.CodeHash && !code.UsedAt.Valid {
+			m.recoveryCodes[i].UsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memoryStore) CreateLoginChallenge(ctx context.Context, arg database.CreateLoginChallengeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenges[arg.TokenHash] = database.LoginChallenge{
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (m *memoryStore) GetLoginChallenge(ctx context.Context, tokenHash string) (database.LoginChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[tokenHash]
+	if !ok || challenge.UsedAt.Valid || !challenge.ExpiresAt.After(time.Now()) {
+		return database.LoginChallenge{}, sql.ErrNoRows
+	}
+	return challenge, nil
+}
+
+func (m *memoryStore) ClaimLoginChallenge(ctx context.Context, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[tokenHash]
+	if !ok || challenge.UsedAt.Valid || !challenge.ExpiresAt.After(time.Now()) {
+		return 0, nil
+	}
+	challenge.UsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.challenges[tokenHash] = challenge
+	return 1, nil
+}
+
+func (m *memoryStore) CreateOIDCState(ctx context.Context, arg database.CreateOIDCStateParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[arg.StateHash] = database.OidcState{
+		StateHash:    arg.StateHash,
+		Provider:     arg.Provider,
+		Nonce:        arg.Nonce,
+		CodeVerifier: arg.CodeVerifier,
+		UserID:       arg.UserID,
+		CreatedAt:    time.Now().UTC(),
+		ExpiresAt:    arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (m *memoryStore) ClaimOIDCState(ctx context.Context, stateHash string) (database.OidcState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[stateHash]
+	if !ok {
+		return database.OidcState{}, sql.ErrNoRows
+	}
+	delete(m.states, stateHash)
+	return state, nil
+}
+
+func (m *memoryStore) DeleteExpiredOIDCStates(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryStore) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, identity := range m.identities {
+		if identity.Provider == arg.Provider && identity.Subject == arg.Subject {
+			return identity, nil
+		}
+	}
+	return database.UserIdentity{}, sql.ErrNoRows
+}
+
+func (m *memoryStore) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	identity := database.UserIdentity{
+		ID:          uuid.New(),
+		UserID:      arg.UserID,
+		Provider:    arg.Provider,
+		Subject:     arg.Subject,
+		Email:       arg.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	m.identities = append(m.identities, identity)
+	return identity, nil
+}
+
+func (m *memoryStore) TouchUserIdentity(ctx context.Context, arg database.TouchUserIdentityParams) error {
+	return nil
+}
+
+func (m *memoryStore) GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]database.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	identities := []database.UserIdentity{}
+	for _, identity := range m.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (m *memoryStore) DeleteUserIdentity(ctx context.Context, arg database.DeleteUserIdentityParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, identity := range m.identities {
+		if identity.UserID == arg.UserID && identity.Provider == arg.Provider {
+			m.identities = slices.Delete(m.identities, i, i+1)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memoryStore) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token := database.ApiToken{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		TokenHint: arg.TokenHint,
+		Scopes:    arg.Scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	m.apiTokens[token.ID] = token
+	return token, nil
+}
+
+func (m *memoryStore) GetActiveAPIToken(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.apiTokens {
+		expired := token.ExpiresAt.Valid && !token.ExpiresAt.Time.After(time.Now())
+		if token.TokenHash == tokenHash && !token.RevokedAt.Valid && !expired {
+			return token, nil
+		}
+	}
+	return database.ApiToken{}, sql.ErrNoRows
+}
+
+func (m *memoryStore) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token := m.apiTokens[id]
+	token.LastUsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.apiTokens[id] = token
+	return nil
+}
+
+func (m *memoryStore) GetAPITokens(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tokens := []database.ApiToken{}
+	for _, token := range m.apiTokens {
+		if token.UserID == userID && !token.RevokedAt.Valid {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *memoryStore) RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.apiTokens[arg.ID]
+	if !ok || token.UserID != arg.UserID || token.RevokedAt.Valid {
+		return 0, nil
+	}
+	token.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.apiTokens[arg.ID] = token
+	return 1, nil
+}
+
+func (m *memoryStore) GetChirp(ctx context.Context, arg database.GetChirpParams) (database.GetChirpRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chirp, ok := m.chirps[arg.ID]
+	if !ok || chirp.DeletedAt.Valid || (chirp.HiddenAt.Valid && !arg.IncludeHidden) {
+		return database.GetChirpRow{}, sql.ErrNoRows
+	}
+	return m.chirpRow(chirp, arg.ViewerID), nil
+}
+
+func (m *memoryStore) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	chirp := database.Chirp{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Body:        arg.Body,
+		UserID:      arg.UserID,
+		InReplyTo:   arg.InReplyTo,
+		Kind:        arg.Kind,
+		ReferenceID: arg.ReferenceID,
+	}
+	m.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *memoryStore) CountUserChirpsSince(ctx context.Context, arg database.CountUserChirpsSinceParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := int64(0)
+	for _, chirp := range m.chirps {
+		if chirp.UserID == arg.UserID && chirp.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryStore) CreateRechirp(ctx context.Context, arg database.CreateRechirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, chirp := range m.chirps {
+		if chirp.Kind == "rechirp" && chirp.UserID == arg.UserID && chirp.ReferenceID == arg.ReferenceID && !chirp.DeletedAt.Valid {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+	}
+	now := m.now()
+	chirp := database.Chirp{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		UserID:      arg.UserID,
+		Kind:        "rechirp",
+		ReferenceID: arg.ReferenceID,
+	}
+	m.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *memoryStore) EditChirp(ctx context.Context, arg database.EditChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chirp, ok := m.chirps[arg.ID]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	now := m.now()
+	m.revisions = append(m.revisions, database.ChirpRevision{
+		ID:         uuid.New(),
+		ChirpID:    chirp.ID,
+		Body:       chirp.Body,
+		CreatedAt:  chirp.UpdatedAt,
+		ReplacedAt: now,
+	})
+	chirp.Body = arg.Body
+	chirp.UpdatedAt = now
+	chirp.EditedAt = sql.NullTime{Time: now, Valid: true}
+	m.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *memoryStore) GetChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revisions := []database.ChirpRevision{}
+	for _, revision := range slices.Backward(m.revisions) {
+		if revision.ChirpID == chirpID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (m *memoryStore) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, chirp := range m.chirps {
+		deleted := chirp.ID == id || (chirp.Kind == "rechirp" && chirp.ReferenceID.UUID == id)
+		if deleted && !chirp.DeletedAt.Valid {
+			chirp.DeletedAt = sql.NullTime{Time: now, Valid: true}
+			m.chirps[chirp.ID] = chirp
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) GetDeletedChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chirp, ok := m.chirps[id]
+	if !ok || !chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (m *memoryStore) RestoreChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	original, ok := m.chirps[id]
+	if !ok || !original.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	if original.Kind == "rechirp" {
+		if m.chirps[original.ReferenceID.UUID].DeletedAt.Valid {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+		for _, chirp := range m.chirps {
+			if chirp.Kind == "rechirp" && chirp.UserID == original.UserID && chirp.ReferenceID == original.ReferenceID && !chirp.DeletedAt.Valid {
+				return database.Chirp{}, &pq.Error{Code: "23505"}
+			}
+		}
+	}
+	for _, chirp := range m.chirps {
+		if chirp.Kind == "rechirp" && chirp.ReferenceID.UUID == id && chirp.DeletedAt == original.DeletedAt {
+			chirp.DeletedAt = sql.NullTime{}
+			m.chirps[chirp.ID] = chirp
+		}
+	}
+	original.DeletedAt = sql.NullTime{}
+	m.chirps[id] = original
+	return original, nil
+}
+
+func (m *memoryStore) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var purged int64
+	for id, chirp := range m.chirps {
+		if chirp.DeletedAt.Valid && chirp.DeletedAt.Time.Before(deletedAt.Time) {
+			delete(m.chirps, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *memoryStore) GetChirpAncestors(ctx context.Context, arg database.GetChirpAncestorsParams) ([]database.GetChirpAncestorsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := []database.GetChirpAncestorsRow{}
+	for parent := m.chirps[arg.ID].InReplyTo; parent.Valid; parent = m.chirps[parent.UUID].InReplyTo {
+		chirp, ok := m.chirps[parent.UUID]
+		if !ok {
+			break
+		}
+		if chirp.DeletedAt.Valid || (chirp.HiddenAt.Valid && !arg.IncludeHidden) {
+			continue
+		}
+		rows = slices.Insert(rows, 0, database.GetChirpAncestorsRow(m.chirpRow(chirp, arg.ViewerID)))
+	}
+	return rows, nil
+}
+
+func (m *memoryStore) GetChirpDescendants(ctx context.Context, arg database.GetChirpDescendantsParams) ([]database.GetChirpDescendantsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	depths := map[uuid.UUID]int32{arg.ID: 0}
+	for added := true; added; {
+		added = false
+		for _, chirp := range m.chirps {
+			if _, ok := depths[chirp.ID]; ok || !chirp.InReplyTo.Valid {
+				continue
+			}
+			if depth, ok := depths[chirp.InReplyTo.UUID]; ok {
+				depths[chirp.ID] = depth + 1
+				added = true
+			}
+		}
+	}
+	rows := m.chirpRows(arg.ViewerID, arg.IncludeHidden, func(chirp database.Chirp) bool {
+		return depths[chirp.ID] > 0
+	})
+	pageRows := []database.GetChirpDescendantsRow{}
+	for _, row := range page(rows, rowChirp, arg.CursorCreatedAt, arg.CursorID, false, arg.PageLimit) {
+		pageRows = append(pageRows, database.GetChirpDescendantsRow{
+			Chirp:      row.Chirp,
+			LikeCount:  row.LikeCount,
+			LikedByMe:  row.LikedByMe,
+			ReplyCount: row.ReplyCount,
+			Depth:      depths[row.Chirp.ID],
+		})
+	}
+	return pageRows, nil
+}
+
+// chirpRows returns the visible chirps that keep accepts, oldest first, with the counts of the viewer.
+func (m *memoryStore) chirpRows(viewerID uuid.NullUUID, includeHidden bool, keep func(database.Chirp) bool) []database.GetChirpRow {
+	rows := []database.GetChirpRow{}
+	for _, chirp := range m.chirps {
+		if chirp.DeletedAt.Valid || (chirp.HiddenAt.Valid && !includeHidden) || !keep(chirp) {
+			continue
+		}
+		rows = append(rows, m.chirpRow(chirp, viewerID))
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return chirpBefore(rows[i].Chirp, rows[j].Chirp.CreatedAt, rows[j].Chirp.ID)
+	})
+	return rows
+}
+
+func (m *memoryStore) chirpRow(chirp database.Chirp, viewerID uuid.NullUUID) database.GetChirpRow {
+	row := database.GetChirpRow{Chirp: chirp}
+	for like := range m.likes {
+		if like.chirpID == chirp.ID {
+			row.LikeCount++
+			row.LikedByMe = row.LikedByMe || (viewerID.Valid && like.userID == viewerID.UUID)
+		}
+	}
+	for _, reply := range m.chirps {
+		if reply.InReplyTo.Valid && reply.InReplyTo.UUID == chirp.ID && !reply.DeletedAt.Valid && !reply.HiddenAt.Valid {
+			row.ReplyCount++
+		}
+	}
+	return row
+}
+
+// chirpBefore compares (created_at, id) like the row comparison of the page queries.
+func chirpBefore(chirp database.Chirp, createdAt time.Time, id uuid.UUID) bool {
+	if !chirp.CreatedAt.Equal(createdAt) {
+		return chirp.CreatedAt.Before(createdAt)
+	}
+	return bytes.Compare(chirp.ID[:], id[:]) < 0
+}
+
+// page keeps the rows after the cursor, in the order of the rows, up to limit.
+func page[T any](rows []T, chirp func(T) database.Chirp, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, desc bool, limit int32) []T {
+	if desc {
+		slices.Reverse(rows)
+	}
+	kept := []T{}
+	for _, row := range rows {
+		if cursorCreatedAt.Valid {
+			before := chirpBefore(chirp(row), cursorCreatedAt.Time, cursorID.UUID)
+			after := !before && chirp(row).ID != cursorID.UUID
+			if (desc && !before) || (!desc && !after) {
+				continue
+			}
+		}
+		if len(kept) == int(limit) {
+			break
+		}
+		kept = append(kept, row)
+	}
+	return kept
+}
+
+func (m *memoryStore) GetChirpsPageAsc(ctx context.Context, arg database.GetChirpsPageAscParams) ([]database.GetChirpsPageAscRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := m.chirpRows(arg.ViewerID, arg.IncludeHidden, func(chirp database.Chirp) bool {
+		return !arg.AuthorID.Valid || chirp.UserID == arg.AuthorID.UUID
+	})
+	pageRows := []database.GetChirpsPageAscRow{}
+	for _, row := range page(rows, rowChirp, arg.CursorCreatedAt, arg.CursorID, false, arg.PageLimit) {
+		pageRows = append(pageRows, database.GetChirpsPageAscRow(row))
+	}
+	return pageRows, nil
+}
+
+func (m *memoryStore) GetChirpsPageDesc(ctx context.Context, arg database.GetChirpsPageDescParams) ([]database.GetChirpsPageDescRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := m.chirpRows(arg.ViewerID, arg.IncludeHidden, func(chirp database.Chirp) bool {
+		return !arg.AuthorID.Valid || chirp.UserID == arg.AuthorID.UUID
+	})
+	pageRows := []database.GetChirpsPageDescRow{}
+	for _, row := range page(rows, rowChirp, arg.CursorCreatedAt, arg.CursorID, true, arg.PageLimit) {
+		pageRows = append(pageRows, database.GetChirpsPageDescRow(row))
+	}
+	return pageRows, nil
+}
+
+func rowChirp(row database.GetChirpRow) database.Chirp {
+	return row.Chirp
+}
+
+// SearchChirps finds the chirps that contain the query, marking it like ts_headline with control characters.
+func (m *memoryStore) SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	query := strings.ToLower(arg.SearchQuery)
+	rows := m.chirpRows(arg.ViewerID, arg.IncludeHidden, func(chirp database.Chirp) bool {
+		return strings.Contains(strings.ToLower(chirp.Body), query) && (!arg.AuthorID.Valid || chirp.UserID == arg.AuthorID.UUID)
+	})
+	if arg.Sort == "desc" {
+		slices.Reverse(rows)
+	}
+
+	results := []database.SearchChirpsRow{}
+	for i, row := range rows {
+		if i < int(arg.PageOffset) || len(results) == int(arg.PageLimit) {
+			continue
+		}
+		body := strings.NewReplacer("\x02", "", "\x03", "").Replace(row.Chirp.Body)
+		start := strings.Index(strings.ToLower(body), query)
+		results = append(results, database.SearchChirpsRow{
+			Chirp:      row.Chirp,
+			LikeCount:  row.LikeCount,
+			LikedByMe:  row.LikedByMe,
+			ReplyCount: row.ReplyCount,
+			Rank:       1,
+			Snippet:    body[:start] + "\x02" + body[start:start+len(query)] + "\x03" + body[start+len(query):],
+		})
+	}
+	return results, nil
+}
+
+func (m *memoryStore) GetChirpsByIDs(ctx context.Context, arg database.GetChirpsByIDsParams) ([]database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chirps := []database.Chirp{}
+	for _, id := range arg.Ids {
+		chirp, ok := m.chirps[id]
+		if ok && !chirp.DeletedAt.Valid && (!chirp.HiddenAt.Valid || arg.IncludeHidden) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps, nil
+}
+
+func (m *memoryStore) GetTimeline(ctx context.Context, arg database.GetTimelineParams) ([]database.GetTimelineRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	followed := map[uuid.UUID]bool{}
+	for _, follow := range m.follows {
+		if follow.FollowerID == arg.UserID {
+			followed[follow.FolloweeID] = true
+		}
+	}
+	rows := m.chirpRows(arg.ViewerID, arg.IncludeHidden, func(chirp database.Chirp) bool {
+		return followed[chirp.UserID]
+	})
+	pageRows := []database.GetTimelineRow{}
+	for _, row := range page(rows, rowChirp, arg.CursorCreatedAt, arg.CursorID, true, arg.PageLimit) {
+		pageRows = append(pageRows, database.GetTimelineRow(row))
+	}
+	return pageRows, nil
+}
+
+func (m *memoryStore) CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, report := range m.reports {
+		if report.ChirpID == arg.ChirpID && report.ReporterID == arg.ReporterID {
+			return database.ChirpReport{}, sql.ErrNoRows
+		}
+	}
+	report := database.ChirpReport{
+		ID:         uuid.New(),
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+		Status:     reportStatusOpen,
+		CreatedAt:  time.Now().UTC(),
+	}
+	m.reports = append(m.reports, report)
+	return report, nil
+}
+
+func (m *memoryStore) GetChirpReport(ctx context.Context, id uuid.UUID) (database.GetChirpReportRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, report := range m.reports {
+		if report.ID == id {
+			return database.GetChirpReportRow{ChirpReport: report, AuthorID: m.chirps[report.ChirpID].UserID}, nil
+		}
+	}
+	return database.GetChirpReportRow{}, sql.ErrNoRows
+}
+
+func (m *memoryStore) report(id uuid.UUID) database.ChirpReport {
+	row, _ := m.GetChirpReport(context.Background(), id)
+	return row.ChirpReport
+}
+
+func (m *memoryStore) GetReportQueue(ctx context.Context, arg database.GetReportQueueParams) ([]database.GetReportQueueRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := []database.GetReportQueueRow{}
+	for _, report := range m.reports {
+		if report.Status != arg.Status ||
+			(arg.Reason.Valid && report.Reason != arg.Reason.String) ||
+			(arg.AssignedTo.Valid && report.AssignedTo != arg.AssignedTo) ||
+			(arg.Unassigned && report.AssignedTo.Valid) {
+			continue
+		}
+		chirp := m.chirps[report.ChirpID]
+		rows = append(rows, database.GetReportQueueRow{
+			ChirpReport:   report,
+			AuthorID:      chirp.UserID,
+			ChirpBody:     chirp.Body,
+			ChirpHiddenAt: chirp.HiddenAt,
+		})
+	}
+	return rows, nil
+}
+
+func (m *memoryStore) AssignChirpReport(ctx context.Context, arg database.AssignChirpReportParams) (database.ChirpReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.reports {
+		if m.reports[i].ID == arg.ID {
+			m.reports[i].AssignedTo = arg.AssignedTo
+			return m.reports[i], nil
+		}
+	}
+	return database.ChirpReport{}, sql.ErrNoRows
+}
+
+func (m *memoryStore) ModerateChirpReport(ctx context.Context, arg database.ModerateChirpReportParams) (database.ModerationAction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.reports, func(report database.ChirpReport) bool {
+		return report.ID == arg.ReportID && report.Status == reportStatusOpen
+	})
+	if i < 0 {
+		return database.ModerationAction{}, sql.ErrNoRows
+	}
+	now := time.Now().UTC()
+	resolved := m.reports[i]
+	resolved.Status = arg.Status
+	resolved.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+	if !resolved.AssignedTo.Valid {
+		resolved.AssignedTo = uuid.NullUUID{UUID: arg.ModeratorID, Valid: true}
+	}
+	m.reports[i] = resolved
+
+	switch arg.Action {
+	case moderationHide:
+		chirp := m.chirps[resolved.ChirpID]
+		if !chirp.HiddenAt.Valid {
+			chirp.HiddenAt = sql.NullTime{Time: now, Valid: true}
+		}
+		m.chirps[chirp.ID] = chirp
+		for j := range m.reports {
+			if m.reports[j].ChirpID == resolved.ChirpID && m.reports[j].Status == reportStatusOpen {
+				m.reports[j].Status = arg.Status
+				m.reports[j].ResolvedAt = sql.NullTime{Time: now, Valid: true}
+			}
+		}
+	case moderationSuspend:
+		user := m.users[arg.AuthorID]
+		user.SuspendedUntil = arg.SuspendedUntil
+		user.SuspendedAt = sql.NullTime{Time: now, Valid: true}
+		m.users[arg.AuthorID] = user
+		m.revokeUserSessions(arg.AuthorID)
+	}
+
+	action := database.ModerationAction{
+		ID:          uuid.New(),
+		ModeratorID: uuid.NullUUID{UUID: arg.ModeratorID, Valid: true},
+		Action:      arg.Action,
+		ReportID:    uuid.NullUUID{UUID: resolved.ID, Valid: true},
+		ChirpID:     uuid.NullUUID{UUID: resolved.ChirpID, Valid: true},
+		UserID:      uuid.NullUUID{UUID: arg.AuthorID, Valid: true},
+		Note:        arg.Note,
+		CreatedAt:   now,
+	}
+	m.actions = append(m.actions, action)
+	return action, nil
+}
+
+func (m *memoryStore) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	action := database.ModerationAction{
+		ID:          uuid.New(),
+		ModeratorID: arg.ModeratorID,
+		Action:      arg.Action,
+		ReportID:    arg.ReportID,
+		ChirpID:     arg.ChirpID,
+		UserID:      arg.UserID,
+		Note:        arg.Note,
+		CreatedAt:   time.Now().UTC(),
+	}
+	m.actions = append(m.actions, action)
+	return action, nil
+}
+
+func (m *memoryStore) CreateFollow(ctx context.Context, arg database.CreateFollowParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, follow := range m.follows {
+		if follow.FollowerID == arg.FollowerID && follow.FolloweeID == arg.FolloweeID {
+			return nil
+		}
+	}
+	m.follows = append(m.follows, database.Follow{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID, CreatedAt: m.now()})
+	return nil
+}
+
+func (m *memoryStore) DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.follows = slices.DeleteFunc(m.follows, func(follow database.Follow) bool {
+		return follow.FollowerID == arg.FollowerID && follow.FolloweeID == arg.FolloweeID
+	})
+	return nil
+}
+
+// GetFollowers returns the newest followers first; the cursor is not supported.
+func (m *memoryStore) GetFollowers(ctx context.Context, arg database.GetFollowersParams) ([]database.GetFollowersRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := []database.GetFollowersRow{}
+	for i := len(m.follows) - 1; i >= 0 && len(rows) < int(arg.PageLimit); i-- {
+		if m.follows[i].FolloweeID == arg.UserID {
+			rows = append(rows, database.GetFollowersRow{FollowerID: m.follows[i].FollowerID, CreatedAt: m.follows[i].CreatedAt})
+		}
+	}
+	return rows, nil
+}
+
+// GetFollowing returns the newest followed users first; the cursor is not supported.
+func (m *memoryStore) GetFollowing(ctx context.Context, arg database.GetFollowingParams) ([]database.GetFollowingRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := []database.GetFollowingRow{}
+	for i := len(m.follows) - 1; i >= 0 && len(rows) < int(arg.PageLimit); i-- {
+		if m.follows[i].FollowerID == arg.UserID {
+			rows = append(rows, database.GetFollowingRow{FolloweeID: m.follows[i].FolloweeID, CreatedAt: m.follows[i].CreatedAt})
+		}
+	}
+	return rows, nil
+}
+
+func (m *memoryStore) CreateChirpLike(ctx context.Context, arg database.CreateChirpLikeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.likes[likeKey{userID: arg.UserID, chirpID: arg.ChirpID}] = true
+	return nil
+}
+
+func (m *memoryStore) DeleteChirpLike(ctx context.Context, arg database.DeleteChirpLikeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.likes, likeKey{userID: arg.UserID, chirpID: arg.ChirpID})
+	return nil
+}
+
+func (m *memoryStore) GetFilteredWords(ctx context.Context) ([]database.FilteredWord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	words := []database.FilteredWord{}
+	for _, word := range m.filterWords {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Word < words[j].Word })
+	return words, nil
+}
+
+func (m *memoryStore) UpsertFilteredWord(ctx context.Context, arg database.UpsertFilteredWordParams) (database.FilteredWord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	word := database.FilteredWord{Word: arg.Word, Policy: arg.Policy, UpdatedAt: m.now()}
+	m.filterWords[arg.Word] = word
+	return word, nil
+}
+
+func (m *memoryStore) DisableFilteredWord(ctx context.Context, arg database.DisableFilteredWordParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	word, ok := m.filterWords[arg.Word]
+	if !ok {
+		word = database.FilteredWord{Word: arg.Word, Policy: arg.Policy}
+	}
+	word.Disabled = true
+	word.UpdatedAt = m.now()
+	m.filterWords[arg.Word] = word
+	return nil
+}
+
+func (m *memoryStore) CreateFlaggedChirp(ctx context.Context, arg database.CreateFlaggedChirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flagged[arg.ChirpID] = database.FlaggedChirp{ChirpID: arg.ChirpID, Words: arg.Words, CreatedAt: m.now()}
+	return nil
+}
+
+func (m *memoryStore) GetFlaggedChirps(ctx context.Context, arg database.GetFlaggedChirpsParams) ([]database.GetFlaggedChirpsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := []database.GetFlaggedChirpsRow{}
+	for _, flag := range m.flagged {
+		chirp := m.chirps[flag.ChirpID]
+		if chirp.DeletedAt.Valid {
+			continue
+		}
+		rows = append(rows, database.GetFlaggedChirpsRow{Chirp: chirp, Words: flag.Words, FlaggedAt: flag.CreatedAt})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].FlaggedAt.Before(rows[j].FlaggedAt) })
+	flaggedChirp := func(row database.GetFlaggedChirpsRow) database.Chirp {
+		return database.Chirp{ID: row.Chirp.ID, CreatedAt: row.FlaggedAt}
+	}
+	return page(rows, flaggedChirp, arg.CursorCreatedAt, arg.CursorID, false, arg.PageLimit), nil
+}
+
+func (m *memoryStore) DeleteFlaggedChirp(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.flagged[chirpID]; !ok {
+		return 0, nil
+	}
+	delete(m.flagged, chirpID)
+	return 1, nil
+}